@@ -3,28 +3,89 @@ package firebase
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // firebaseAPI is the internal implementation of the Firebase API client.
-type firebaseAPI struct{}
+type firebaseAPI struct {
+	// codec marshals request bodies and unmarshals responses. If nil, the
+	// default codec is used.
+	codec Codec
 
-func doFirebaseRequest(client *http.Client, method, path, auth, accept string, body interface{}, params map[string]string) (*http.Response, error) {
+	// interceptors wrap every Call and Stream, the first one outermost.
+	interceptors []Interceptor
+
+	// metrics receives the measurements of calls and streams. If nil, they
+	// are not measured.
+	metrics MetricsCollector
+
+	// tracer traces calls and streams. If nil, they are not traced.
+	tracer Tracer
+
+	// authMode is how Call and Stream send the auth token.
+	authMode AuthMode
+}
+
+func (f *firebaseAPI) getTracer() Tracer {
+	if f.tracer == nil {
+		return nopTracer{}
+	}
+
+	return f.tracer
+}
+
+func (f *firebaseAPI) getCodec() Codec {
+	if f.codec == nil {
+		return defaultCodec
+	}
+
+	return f.codec
+}
+
+func (f *firebaseAPI) getMetrics() MetricsCollector {
+	if f.metrics == nil {
+		return nopCollector{}
+	}
+
+	return f.metrics
+}
+
+// countBody reports the body of req to metrics as it is sent.
+func countBody(req *http.Request, metrics MetricsCollector) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	req.Body = &countingReader{ReadCloser: req.Body, count: metrics.BytesSent}
+}
+
+// countResponse reports the body of response to metrics as it is read, and
+// sets size to the number of bytes read so far.
+func countResponse(response *http.Response, metrics MetricsCollector, size *int64) {
+	*size = 0
+	response.Body = &countingReader{ReadCloser: response.Body, count: func(n int64) {
+		*size += n
+		metrics.BytesReceived(n)
+	}}
+}
+
+// newFirebaseRequest builds a request to the Firebase REST API.
+func newFirebaseRequest(ctx context.Context, codec Codec, method, path, auth string, mode AuthMode, header http.Header, body interface{}, params map[string]string) (*http.Request, error) {
 	// Every path needs to end in .json for the Firebase REST API
 	path += ".json"
 	qs := url.Values{}
+	authHeader := http.Header{}
 
-	// if the client has an auth, set it as a query string.
-	// the caller can also override this on a per-call basis
-	// which will happen via params below
-	if len(auth) > 0 {
-		qs.Set("auth", auth)
-	}
+	// the auth token goes in the query string or a header, as the mode
+	// says. an auth param still overrides it, for backwards compatibility.
+	mode.authorize(auth, qs, authHeader)
 
 	for k, v := range params {
 		qs.Set(k, v)
@@ -34,63 +95,235 @@ func doFirebaseRequest(client *http.Client, method, path, auth, accept string, b
 		path += "?" + qs.Encode()
 	}
 
-	encodedBody, err := json.Marshal(body)
+	bodyReader, err := requestBody(codec, body)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(method, path, bytes.NewReader(encodedBody))
+	req, err := http.NewRequestWithContext(ctx, method, path, bodyReader)
+	if err != nil {
+		return nil, redactError(err)
+	}
+
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	for key, values := range authHeader {
+		req.Header[key] = values
+	}
+
+	return req, nil
+}
+
+// drainAndClose reads what's left of a response body, up to a limit, before
+// closing it. A connection can only be reused once its last response was read
+// to the end.
+func drainAndClose(body io.ReadCloser) {
+	io.CopyN(io.Discard, body, maxDrainBytes)
+	body.Close()
+}
+
+// maxDrainBytes is the most drainAndClose reads. Past that, closing the
+// connection is cheaper than reading the rest.
+const maxDrainBytes = 64 << 10
+
+// requestBody returns the reader a request's body is sent from. Readers and
+// json.RawMessage values are sent as they are; anything else is marshalled to
+// JSON by the codec first.
+func requestBody(codec Codec, body interface{}) (io.Reader, error) {
+	switch b := body.(type) {
+	case json.RawMessage:
+		return bytes.NewReader(b), nil
+	case io.ReadCloser:
+		// The http client closes request bodies, but this one belongs to
+		// the caller.
+		return io.NopCloser(b), nil
+	case io.Reader:
+		return b, nil
+	}
+
+	encodedBody, err := codec.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
 
-	if accept != "" {
-		req.Header.Add("Accept", accept)
+	return bytes.NewReader(encodedBody), nil
+}
+
+// bodyRewinder returns a function that prepares body to be sent again, before
+// each attempt of a request. A body read from an io.Reader can only be sent
+// again if it can seek back to where it started; canRetry is false otherwise.
+func bodyRewinder(body interface{}) (rewind func() error, canRetry bool) {
+	noop := func() error { return nil }
+
+	reader, isReader := body.(io.Reader)
+	if !isReader {
+		return noop, true
+	}
+
+	seeker, isSeeker := reader.(io.Seeker)
+	if !isSeeker {
+		return noop, false
 	}
 
-	req.Close = true
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return noop, false
+	}
 
-	return client.Do(req)
+	return func() error {
+		_, err := seeker.Seek(start, io.SeekStart)
+		return err
+	}, true
 }
 
 // Call invokes the appropriate HTTP method on a given Firebase URL.
 func (f *firebaseAPI) Call(method, path, auth string, body interface{}, params map[string]string, dest interface{}) error {
+	return f.callContext(context.Background(), method, path, auth, f.authMode, body, params, dest)
+}
+
+// callContext is Call, within ctx: the call is traced as a child of the span
+// in ctx, if any, and canceled along with ctx.
+func (f *firebaseAPI) callContext(ctx context.Context, method, path, auth string, mode AuthMode, body interface{}, params map[string]string, dest interface{}) error {
+	ctx, span := f.getTracer().Start(ctx, "firebase "+method)
+
+	req := newRequest(ctx, method, path, auth, mode, body, params)
+	req.codec = f.getCodec()
+	req.Destination = dest
+
+	err := f.callChain()(req)
+	endSpan(span, req, err)
+
+	return err
+}
+
+// call is the end of the interceptor chain of Call: it sends the request to
+// Firebase, and decodes the response into the request's destination.
+func (f *firebaseAPI) call(r *Request) error {
 	var response *http.Response
 	var err error
-	retries := 10
+	retries := callMaxTries - 1
+	if retries < 0 {
+		retries = 0
+	}
+	delay := minRetryDelay
+
+	metrics := f.getMetrics()
+	start := time.Now()
+	defer func() {
+		metrics.CallDone(r.Method, r.StatusCode, time.Since(start))
+	}()
+
+	rewind, canRetry := bodyRewinder(r.Body)
+	if !canRetry {
+		retries = 0
+	}
+
+	header := r.Header.Clone()
+	dest := r.Destination
+	etagDest, wantsETag := dest.(*etagDestination)
+	if wantsETag {
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("X-Firebase-ETag", "true")
+		if etagDest.ifMatch != "" {
+			header.Set("if-match", etagDest.ifMatch)
+		}
+		dest = etagDest.destination
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			// Back off, so that retries don't pile up on a Firebase that
+			// is down or overloaded.
+			select {
+			case <-r.Context.Done():
+				return r.Context.Err()
+			case <-time.After(delay):
+			}
+
+			delay *= 2
+			if delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+
+			metrics.CallRetried(r.Method)
+			r.Retries = attempt
+		}
 
-	for {
-		response, err = doFirebaseRequest(httpClient, method, path, auth, "",
-			body, params)
-		if err != nil && retries == 0 {
+		if err = rewind(); err != nil {
+			return err
+		}
+
+		var req *http.Request
+		req, err = newFirebaseRequest(r.Context, f.getCodec(), r.Method, r.Path,
+			r.Auth, r.AuthMode, header, r.Body, r.Params)
+		if err != nil {
+			return err
+		}
+		countBody(req, metrics)
+
+		response, err = httpClient.Do(req)
+		err = redactError(err)
+		if err != nil && (retries == 0 || r.Context.Err() != nil) {
+			// There's no point in retrying once the context is done.
 			return err
 		} else if err != nil {
 			retries--
 			log.Println("Retry: ", err)
 			continue
 		}
+		countResponse(response, metrics, &r.ResponseSize)
 
-		if response.StatusCode >= 400 && retries > 0 {
+		// A failed condition fails again until the condition changes.
+		if response.StatusCode >= 400 && response.StatusCode != http.StatusPreconditionFailed && retries > 0 {
 			retries--
 			log.Println("Retry: status code == ", response.StatusCode)
-			response.Body.Close()
+			drainAndClose(response.Body)
 			continue
 		}
 
 		break
 	}
 
-	defer response.Body.Close()
+	r.StatusCode = response.StatusCode
+
+	if reader, ok := dest.(readerDestination); ok && response.StatusCode < 400 {
+		// The destination takes over the body, and closes it when done.
+		reader.takeBody(response.Body)
+		return nil
+	}
+
+	defer drainAndClose(response.Body)
+
+	if response.StatusCode == http.StatusPreconditionFailed && wantsETag {
+		return &ETagMismatchError{ETag: response.Header.Get("ETag")}
+	}
 
-	decoder := json.NewDecoder(response.Body)
 	if response.StatusCode >= 400 {
 		err := &FirebaseError{}
-		decoder.Decode(err)
+		json.NewDecoder(response.Body).Decode(err)
 		return err
 	}
 
+	if wantsETag && etagDest.etag != nil {
+		*etagDest.etag = response.Header.Get("ETag")
+	}
+
+	// Silent writes (print=silent) are answered with 204 No Content.
+	if response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
 	if dest != nil && response.ContentLength != 0 {
-		err = decoder.Decode(dest)
+		if streamer, ok := dest.(streamingDestination); ok {
+			return streamer.decodeStream(json.NewDecoder(response.Body))
+		}
+
+		err = f.getCodec().NewDecoder(response.Body).Decode(dest)
 		if err != nil {
 			return err
 		}
@@ -102,12 +335,59 @@ func (f *firebaseAPI) Call(method, path, auth string, body interface{}, params m
 // Stream implements an SSE/Event Source client that watches for changes at a
 // given Firebase location.
 func (f *firebaseAPI) Stream(path, auth string, body interface{}, params map[string]string, stop <-chan bool) (<-chan RawEvent, error) {
-	response, err := doFirebaseRequest(streamClient, "GET", path, auth,
-		"text/event-stream", body, params)
+	return f.streamContext(context.Background(), path, auth, f.authMode, body, params, stop)
+}
+
+// streamContext is Stream, within ctx. The span of a stream covers opening
+// it; the stream is closed along with ctx.
+func (f *firebaseAPI) streamContext(ctx context.Context, path, auth string, mode AuthMode, body interface{}, params map[string]string, stop <-chan bool) (<-chan RawEvent, error) {
+	ctx, span := f.getTracer().Start(ctx, "firebase stream")
+
+	req := newRequest(ctx, "GET", path, auth, mode, body, params)
+	req.codec = f.getCodec()
+	req.Stream = true
+
+	events, err := f.streamChain(stop)(req)
+	endSpan(span, req, err)
+
+	return events, err
+}
+
+// stream is the end of the interceptor chain of Stream: it opens the stream,
+// and reads its events until stop is closed.
+func (f *firebaseAPI) stream(r *Request, stop <-chan bool) (<-chan RawEvent, error) {
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Accept", "text/event-stream")
+
+	req, err := newFirebaseRequest(r.Context, f.getCodec(), "GET", r.Path,
+		r.Auth, r.AuthMode, header, r.Body, r.Params)
 	if err != nil {
 		return nil, err
 	}
 
+	// A stream holds on to its connection for as long as it's watched, so it
+	// gets one of its own rather than one from the pool.
+	req.Close = true
+
+	metrics := f.getMetrics()
+	countBody(req, metrics)
+
+	response, err := streamClient.Do(req)
+	if err != nil {
+		return nil, redactError(err)
+	}
+
+	// The body of a stream is read long after the request is done, so its
+	// size is not part of the request.
+	var streamed int64
+
+	r.StatusCode = response.StatusCode
+	countResponse(response, metrics, &streamed)
+	metrics.StreamOpened()
+
 	go func() {
 		<-stop
 		response.Body.Close()
@@ -153,6 +433,7 @@ func (f *firebaseAPI) Stream(path, auth string, body interface{}, params map[str
 			event.Event = strings.Replace(firstLine, "event: ", "", 1)
 			event.Data = strings.Replace(line, "data: ", "", 1)
 
+			metrics.EventReceived(event.Event)
 			events <- event
 			firstLine = ""
 			lineBuf = []byte{}
@@ -162,6 +443,8 @@ func (f *firebaseAPI) Stream(path, auth string, body interface{}, params map[str
 			err = nil
 		}
 
+		metrics.StreamClosed()
+
 		closeEvent := RawEvent{Error: err}
 		events <- closeEvent
 		close(events)
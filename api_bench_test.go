@@ -0,0 +1,65 @@
+package firebase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// benchmarkCalls measures GETs to a local TLS server, through a call client
+// configured by configure.
+func benchmarkCalls(b *testing.B, configure func(*http.Transport)) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"A":1}`)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	client := newCallClient(time.Second, 10*time.Second, maxIdleConnsDefault)
+	transport := client.Transport.(*http.Transport)
+	transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	configure(transport)
+	defer transport.CloseIdleConnections()
+
+	saved := httpClient
+	httpClient = client
+	defer func() { httpClient = saved }()
+
+	api := &firebaseAPI{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var widget Widget
+		if err := api.Call("GET", server.URL+"/widget", "", nil, nil, &widget); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCallReusedConnection is the current behavior: calls share kept
+// alive HTTP/2 connections.
+func BenchmarkCallReusedConnection(b *testing.B) {
+	benchmarkCalls(b, func(*http.Transport) {})
+}
+
+// BenchmarkCallReusedHTTP1Connection reuses kept alive HTTP/1.1 connections,
+// as with servers that don't support HTTP/2.
+func BenchmarkCallReusedHTTP1Connection(b *testing.B) {
+	benchmarkCalls(b, func(transport *http.Transport) {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	})
+}
+
+// BenchmarkCallNewConnection is the behavior of closing every request's
+// connection, paying for a dial and a TLS handshake on every call.
+func BenchmarkCallNewConnection(b *testing.B) {
+	benchmarkCalls(b, func(transport *http.Transport) {
+		transport.DisableKeepAlives = true
+	})
+}
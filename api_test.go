@@ -1,6 +1,7 @@
 package firebase
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -192,3 +193,50 @@ var _ = Describe("Parsing timeouts / tunables from env variables", func() {
 		})
 	})
 })
+
+var _ = Describe("Call retries", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		requests   []time.Time
+		minDelay   time.Duration
+		maxDelay   time.Duration
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		minDelay, maxDelay = minRetryDelay, maxRetryDelay
+		testServer, testClient = fakeServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, time.Now())
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		callMaxTries = 4
+		minRetryDelay, maxRetryDelay = 20*time.Millisecond, 40*time.Millisecond
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+		callMaxTries = callMaxTriesDefault
+		minRetryDelay, maxRetryDelay = minDelay, maxDelay
+	})
+
+	It("Tries calls callMaxTries times, backing off between tries", func() {
+		var w Widget
+		Expect(testClient.Value(&w)).To(HaveOccurred())
+
+		Expect(requests).To(HaveLen(4))
+		for i, wait := range []time.Duration{20, 40, 40} {
+			Expect(requests[i+1].Sub(requests[i])).To(BeNumerically(">=", wait*time.Millisecond))
+		}
+	})
+
+	It("Stops backing off once the context is done", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		var w Widget
+		Expect(testClient.WithContext(ctx).Value(&w)).To(Equal(context.DeadlineExceeded))
+		Expect(requests).To(HaveLen(2))
+	})
+})
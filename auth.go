@@ -0,0 +1,130 @@
+package firebase
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthMode is how a client sends its auth token to Firebase.
+type AuthMode int
+
+const (
+	// AuthQuery sends the token in the auth query string param. It is how
+	// legacy database secrets and ID tokens are sent, and the default.
+	AuthQuery AuthMode = iota
+
+	// AuthBearer sends the token in an "Authorization: Bearer" header,
+	// which keeps OAuth2 access tokens out of URLs, and so out of proxy
+	// logs.
+	AuthBearer
+
+	// AuthAccessToken sends the token in the access_token query string
+	// param.
+	AuthAccessToken
+)
+
+func (m AuthMode) String() string {
+	switch m {
+	case AuthQuery:
+		return "query"
+	case AuthBearer:
+		return "bearer"
+	case AuthAccessToken:
+		return "access_token"
+	default:
+		return "unknown"
+	}
+}
+
+// authorize adds token to the query string or the headers of a request, as
+// the mode says. Nothing is added for an empty token.
+func (m AuthMode) authorize(token string, qs url.Values, header http.Header) {
+	if token == "" {
+		return
+	}
+
+	switch m {
+	case AuthBearer:
+		header.Set("Authorization", "Bearer "+token)
+	case AuthAccessToken:
+		qs.Set("access_token", token)
+	default:
+		qs.Set("auth", token)
+	}
+}
+
+// WithAuthMode makes the client send its auth token as mode says, instead of
+// in the auth query string param. Api implementations passed to NewClient
+// are given the token, but not the mode.
+func WithAuthMode(mode AuthMode) ClientOption {
+	return func(c *client) {
+		c.authMode = mode
+	}
+}
+
+// errTooManyRedirects is returned by requests redirected more than
+// maxRedirects times, like net/http does.
+var errTooManyRedirects = errors.New("stopped after 10 redirects")
+
+const maxRedirects = 10
+
+// firebaseDomains are the domains whose hosts are all Firebase's, and so may
+// be given the Authorization header of another one of them.
+var firebaseDomains = []string{"firebaseio.com", "firebasedatabase.app"}
+
+// keepAuthorization is the redirect policy of the clients of calls and
+// streams. net/http drops the Authorization header of requests redirected
+// to another host, but Firebase redirects streams from the database's host to
+// a sibling one (e.g. from x.firebaseio.com to s-1.firebaseio.com). The
+// header is kept on redirects over https to the same host, or from a Firebase
+// host to a sibling of it.
+func keepAuthorization(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errTooManyRedirects
+	}
+
+	original := via[0]
+	authorization := original.Header.Get("Authorization")
+	if authorization == "" || req.Header.Get("Authorization") != "" {
+		return nil
+	}
+
+	if req.URL.Scheme == "https" && firebaseSiblings(original.URL.Hostname(), req.URL.Hostname()) {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return nil
+}
+
+// firebaseSiblings tells whether host and other are the same host, or hosts
+// of the same Firebase domain (e.g. a database's host and the host of its
+// shard, under firebaseio.com or a region of firebasedatabase.app).
+func firebaseSiblings(host, other string) bool {
+	host, other = strings.ToLower(host), strings.ToLower(other)
+	if host == other {
+		return true
+	}
+
+	parent := func(host string) string {
+		i := strings.Index(host, ".")
+		if i < 0 {
+			return ""
+		}
+		return host[i+1:]
+	}
+
+	hostParent := parent(host)
+	if hostParent != parent(other) {
+		return false
+	}
+
+	for _, domain := range firebaseDomains {
+		if hostParent == domain || strings.HasSuffix(hostParent, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package firebase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// redirected returns the redirect to to of a request to from, with a bearer
+// token, as keepAuthorization lets it through.
+func redirected(from, to string) *http.Request {
+	original, _ := http.NewRequest("GET", from, nil)
+	original.Header.Set("Authorization", "Bearer secret")
+	next, _ := http.NewRequest("GET", to, nil)
+
+	Expect(keepAuthorization(next, []*http.Request{original})).To(BeNil())
+	return next
+}
+
+var _ = Describe("Auth modes", func() {
+	var (
+		testServer *httptest.Server
+		requests   chan *http.Request
+	)
+
+	BeforeEach(func() {
+		requests = make(chan *http.Request, 10)
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests <- r
+			w.Write([]byte(`{"A":1}`))
+		}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	read := func(ref Reference) *http.Request {
+		var w Widget
+		Expect(ref.Value(&w)).To(BeNil())
+		Expect(w.A).To(Equal(1))
+		return <-requests
+	}
+
+	It("Sends the token in the auth param by default", func() {
+		r := read(NewClient(testServer.URL, "secret", nil))
+		Expect(r.URL.Query().Get("auth")).To(Equal("secret"))
+		Expect(r.Header.Get("Authorization")).To(BeEmpty())
+	})
+
+	It("Sends the token in an Authorization header in bearer mode", func() {
+		r := read(NewClient(testServer.URL, "secret", nil, WithAuthMode(AuthBearer)))
+		Expect(r.Header.Get("Authorization")).To(Equal("Bearer secret"))
+		Expect(r.URL.RawQuery).NotTo(ContainSubstring("secret"))
+	})
+
+	It("Sends the token in the access_token param in access token mode", func() {
+		r := read(NewClient(testServer.URL, "secret", nil, WithAuthMode(AuthAccessToken)))
+		Expect(r.URL.Query().Get("access_token")).To(Equal("secret"))
+		Expect(r.URL.Query()).NotTo(HaveKey("auth"))
+	})
+
+	It("Sends nothing without a token", func() {
+		r := read(NewClient(testServer.URL, "", nil, WithAuthMode(AuthBearer)))
+		Expect(r.Header.Get("Authorization")).To(BeEmpty())
+		Expect(r.URL.RawQuery).To(BeEmpty())
+	})
+
+	It("Overrides the client's token and mode with WithAuth", func() {
+		c := NewClient(testServer.URL, "secret", nil)
+
+		r := read(c.WithAuth("access", AuthBearer).Child("a"))
+		Expect(r.Header.Get("Authorization")).To(Equal("Bearer access"))
+		Expect(r.URL.Query()).NotTo(HaveKey("auth"))
+
+		r = read(c.Child("a"))
+		Expect(r.URL.Query().Get("auth")).To(Equal("secret"))
+	})
+
+	It("Uses the mode of the client in streams", func() {
+		c := NewClient(testServer.URL, "secret", nil, WithAuthMode(AuthBearer))
+		stop := make(chan bool)
+		defer close(stop)
+
+		_, err := c.Watch(func(string, []byte) (interface{}, error) { return nil, nil }, stop)
+		Expect(err).To(BeNil())
+
+		r := <-requests
+		Expect(r.Header.Get("Authorization")).To(Equal("Bearer secret"))
+	})
+
+	It("Lets interceptors see and change the mode", func() {
+		upgrade := CallInterceptor(func(req *Request, next CallFunc) error {
+			Expect(req.AuthMode).To(Equal(AuthQuery))
+			req.AuthMode = AuthAccessToken
+			return next(req)
+		})
+
+		r := read(NewClient(testServer.URL, "secret", nil, WithInterceptors(upgrade)))
+		Expect(r.URL.Query().Get("access_token")).To(Equal("secret"))
+	})
+
+	It("Keeps the Authorization header on redirects to sibling hosts", func() {
+		Expect(redirected("https://x.firebaseio.com/a.json", "https://s-1.firebaseio.com/a.json").
+			Header.Get("Authorization")).To(Equal("Bearer secret"))
+		Expect(redirected("https://x.firebaseio.com/a.json", "http://s-1.firebaseio.com/a.json").
+			Header.Get("Authorization")).To(BeEmpty())
+		Expect(redirected("https://x.firebaseio.com/a.json", "https://evil.com/a.json").
+			Header.Get("Authorization")).To(BeEmpty())
+		Expect(redirected("https://firebaseio.com/a.json", "https://evil.com/a.json").
+			Header.Get("Authorization")).To(BeEmpty())
+		Expect(redirected("https://x.europe-west1.firebasedatabase.app/a.json",
+			"https://s-1.europe-west1.firebasedatabase.app/a.json").
+			Header.Get("Authorization")).To(Equal("Bearer secret"))
+		Expect(redirected("https://db.example.com/a.json", "https://db.example.com/b.json").
+			Header.Get("Authorization")).To(Equal("Bearer secret"))
+	})
+
+	It("Drops the Authorization header on redirects under a public suffix", func() {
+		Expect(redirected("https://db.example.co.uk/a.json", "https://evil.co.uk/a.json").
+			Header.Get("Authorization")).To(BeEmpty())
+		Expect(redirected("https://db.example.com/a.json", "https://other.example.com/a.json").
+			Header.Get("Authorization")).To(BeEmpty())
+	})
+
+	It("Stops after 10 redirects", func() {
+		via := make([]*http.Request, 10)
+		for i := range via {
+			via[i] = &http.Request{URL: &url.URL{}, Header: http.Header{}}
+		}
+		Expect(keepAuthorization(via[0], via)).To(Equal(errTooManyRedirects))
+	})
+})
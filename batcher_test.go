@@ -0,0 +1,173 @@
+package firebase
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WriteBatcher", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		mu         sync.Mutex
+		patches    []map[string]interface{}
+		status     int
+	)
+
+	BeforeEach(func() {
+		patches = nil
+		status = http.StatusNoContent
+		testServer, testClient = fakeServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.Method).To(Equal("PATCH"))
+			Expect(r.URL.Path).To(Equal("/batch.json"))
+			Expect(r.URL.Query().Get("print")).To(Equal("silent"))
+
+			patch := map[string]interface{}{}
+			Expect(json.NewDecoder(r.Body).Decode(&patch)).To(BeNil())
+
+			mu.Lock()
+			patches = append(patches, patch)
+			mu.Unlock()
+
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	sent := func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]interface{}(nil), patches...)
+	}
+
+	newBatcher := func(options ...BatcherOption) *WriteBatcher {
+		return NewWriteBatcher(testClient.Child("batch"), options...)
+	}
+
+	It("Sends the writes of a window as one multi-path PATCH", func() {
+		b := newBatcher(BatchWindow(20 * time.Millisecond))
+
+		r1 := b.Set("a/x", 1)
+		r2 := b.Remove("b")
+		r3 := b.Update("c", map[string]interface{}{"d": "e", "f/g": true})
+
+		Expect(<-r1).To(BeNil())
+		Expect(<-r2).To(BeNil())
+		Expect(<-r3).To(BeNil())
+
+		Expect(sent()).To(Equal([]map[string]interface{}{{
+			"a/x":   float64(1),
+			"b":     nil,
+			"c/d":   "e",
+			"c/f/g": true,
+		}}))
+	})
+
+	It("Queues none of the children of an invalid Update", func() {
+		b := newBatcher(BatchWindow(time.Hour))
+
+		result := b.Update("x", map[string]interface{}{"a": 1, "b": 2, "c.d": 3})
+		Expect(<-result).To(BeAssignableToTypeOf(&InvalidPathError{}))
+
+		result = b.Update("x", map[string]interface{}{"a": 1, "b": make(chan int)})
+		Expect(<-result).To(HaveOccurred())
+
+		result = b.Update("x", map[string]interface{}{"a": 1, "b": 2, "b/c": 3})
+		Expect(<-result).To(BeAssignableToTypeOf(&InvalidPathError{}))
+		Expect(<-b.Update("x", map[string]interface{}{"b": 2, "/b/": 3})).To(
+			MatchError(ContainSubstring("in the same update")))
+
+		Expect(b.Flush()).To(BeNil())
+		Expect(sent()).To(BeEmpty())
+	})
+
+	It("Rejects values that can't be encoded without failing the batch", func() {
+		b := newBatcher(BatchWindow(time.Hour))
+
+		r1 := b.Set("a", 1)
+		Expect(<-b.Set("b", make(chan int))).To(HaveOccurred())
+		Expect(<-b.Set("a/x", make(chan int))).To(HaveOccurred())
+
+		Expect(b.Flush()).To(BeNil())
+		Expect(<-r1).To(BeNil())
+		Expect(sent()).To(Equal([]map[string]interface{}{{"a": float64(1)}}))
+	})
+
+	It("Flushes once the batch is full", func() {
+		b := newBatcher(BatchWindow(time.Hour), BatchSize(2))
+
+		r1 := b.Set("a", 1)
+		r2 := b.Set("b", 2)
+
+		Eventually(r1).Should(Receive(BeNil()))
+		Eventually(r2).Should(Receive(BeNil()))
+		Expect(sent()).To(HaveLen(1))
+	})
+
+	It("Lets the last write win, and deletes replace the writes below", func() {
+		b := newBatcher(BatchWindow(time.Hour))
+
+		b.Set("a", 1)
+		b.Set("a", 2)
+		b.Set("b/x", 1)
+		b.Set("b/y", 1)
+		b.Remove("b")
+
+		Expect(b.Flush()).To(BeNil())
+		Expect(sent()).To(Equal([]map[string]interface{}{{
+			"a": float64(2),
+			"b": nil,
+		}}))
+	})
+
+	It("Merges writes below a pending write into it", func() {
+		b := newBatcher(BatchWindow(time.Hour))
+
+		b.Set("a", map[string]interface{}{"x": 1, "y": map[string]int{"z": 1}})
+		b.Set("a/y/z", 2)
+		b.Remove("a/x")
+		b.Set("a/w/v", "new")
+
+		Expect(b.Flush()).To(BeNil())
+		Expect(sent()).To(Equal([]map[string]interface{}{{
+			"a": map[string]interface{}{
+				"y": map[string]interface{}{"z": float64(2)},
+				"w": map[string]interface{}{"v": "new"},
+			},
+		}}))
+	})
+
+	It("Reports the PATCH's failure to every write of the batch", func() {
+		status = http.StatusBadRequest
+		b := newBatcher(BatchWindow(time.Hour))
+
+		r1 := b.Set("a", 1)
+		r2 := b.Set("a", 2)
+
+		Expect(b.Flush()).To(HaveOccurred())
+		Expect(<-r1).To(HaveOccurred())
+		Expect(<-r2).To(HaveOccurred())
+	})
+
+	It("Rejects invalid paths, and writes after Close", func() {
+		b := newBatcher()
+
+		Expect(<-b.Set("", 1)).To(HaveOccurred())
+		Expect(<-b.Set("a.b", 1)).To(HaveOccurred())
+
+		r := b.Set("a", 1)
+		Expect(b.Close()).To(BeNil())
+		Expect(<-r).To(BeNil())
+		Expect(<-b.Set("a", 1)).To(Equal(ErrBatcherClosed))
+	})
+})
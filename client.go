@@ -3,59 +3,13 @@
 package firebase
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"regexp"
-	"strconv"
+	"sync"
 	"time"
 )
 
-var keyExtractor = regexp.MustCompile(`https://.*/([^/]+)/?$`)
-
-// ServerTimestamp is a Go binding for Firebase's ServerValue.TIMESTAMP fields.
-// When marshalling a variable of ServerTimestamp type into JSON (i.e. to send
-// to Firebase), it takes the following JSON representation, no matter what
-// time value the variable has been assigned:
-//
-//     {".sv":"timestamp"}
-//
-// When this JSON value is sent to Firebase, it is substituted into a number
-// equal to milliseconds since the epoch, as measured by Firebase's servers.
-//
-// When reading a value of this type from Firebase, you receive a number equal
-// to milliseconds since the epoch. That value was computed by Firebase when
-// the JSON detailed above was written. A JSON unmarshal of this type will
-// convert a number of ms since the epoch into a time.Time value.
-//
-// NOTE: This approach results in non-symmetric marshal/unmarshal behavior
-// (i.e. unmarshal(marshal(ServerTimestamp{})) will return an error). It is
-// intended to be used only when reading/writing this value from Firebase.
-//
-// See the Firebase's documentation of ServerValues and timestamps for more
-// details:
-// https://www.firebase.com/docs/rest/api/#section-server-values
-type ServerTimestamp time.Time
-
-func (t ServerTimestamp) MarshalJSON() ([]byte, error) {
-	var serverValue struct {
-		Value string `json:".sv"`
-	}
-
-	serverValue.Value = "timestamp"
-	return json.Marshal(serverValue)
-}
-
-func (t *ServerTimestamp) UnmarshalJSON(b []byte) error {
-	ts, err := strconv.ParseInt(string(b), 10, 64)
-	if err != nil {
-		return err
-	}
-
-	// Firebase reports milliseconds since the epoch, Go counts in ns.
-	*t = ServerTimestamp(time.Unix(0, ts*int64(time.Millisecond)))
-	return nil
-}
-
 // FirebaseError is a Go representation of the error message sent back by Firebase when a
 // request results in an error.
 type FirebaseError struct {
@@ -68,66 +22,312 @@ func (f *FirebaseError) Error() string {
 
 // This is the actual default implementation
 type client struct {
-	// The ordering being enforced on this client
-	Order string
+	// root is the database's base URL (scheme and host), without a trailing
+	// slash.
+	root string
+
+	// path is the location of the client, relative to root.
+	path Path
+
 	// url is the client's base URL used for all calls.
 	url string
 
 	// auth is authentication token used when making calls.
 	// The token is optional and can also be overwritten on an individual
-	// call basis via params.
+	// call basis via WithAuth.
 	auth string
 
+	// authMode is how auth is sent.
+	authMode AuthMode
+
+	// tokens, if set, replaces auth with the tokens it returns.
+	tokens *reuseTokenSource
+
 	// api is the underlying client used to make calls.
 	api Api
 
-	params map[string]string
+	// codec decodes Watch events and ordered results. It is also used by
+	// the default Api implementation.
+	codec Codec
+
+	// interceptors are given to the default Api implementation by NewClient.
+	interceptors []Interceptor
+
+	// metrics receives the measurements of the client's watches, and of the
+	// calls and streams of the default Api implementation.
+	metrics MetricsCollector
+
+	// tracer is given to the default Api implementation by NewClient.
+	tracer Tracer
+
+	// ctx is the context of the client's calls, set by WithContext. If nil,
+	// calls are made without one.
+	ctx context.Context
+
+	// err is set when the client was created with an invalid path. It is
+	// returned by every call that would otherwise contact Firebase.
+	err error
 }
 
-func NewClient(root, auth string, api Api) Client {
+// NewClient returns a reference to the Firebase location at root. If api is
+// nil, the default Api implementation is used. The client can be configured
+// further with options.
+func NewClient(root, auth string, api Api, options ...ClientOption) Client {
+	base, path, err := splitRoot(root)
+	c := &client{
+		root:    base,
+		path:    path,
+		url:     locationURL(base, path),
+		auth:    auth,
+		codec:   defaultCodec,
+		metrics: nopCollector{},
+		err:     err,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
 	if api == nil {
-		api = new(firebaseAPI)
+		api = &firebaseAPI{
+			codec:        c.codec,
+			interceptors: c.interceptors,
+			metrics:      c.metrics,
+			tracer:       c.tracer,
+			authMode:     c.authMode,
+		}
 	}
+	c.api = api
 
-	return &client{url: root, auth: auth, api: api}
+	return c
+}
+
+// locationURL builds the URL of the location at path under the database
+// root, escaping each key of the path.
+func locationURL(root string, path Path) string {
+	return root + "/" + path.Escaped()
+}
+
+// withPath returns a copy of the client that refers to the location at path.
+func (c *client) withPath(path Path) *client {
+	return &client{
+		api:      c.api,
+		codec:    c.codec,
+		metrics:  c.metrics,
+		ctx:      c.ctx,
+		auth:     c.auth,
+		authMode: c.authMode,
+		tokens:   c.tokens,
+		root:     c.root,
+		path:     path,
+		url:      locationURL(c.root, path),
+		err:      c.err,
+	}
+}
+
+// child parses a path relative to the client and returns a copy of the client
+// that refers to it.
+func (c *client) child(path string) (*client, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	childPath, err := childPath(c.path, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.withPath(childPath), nil
 }
 
 func (c *client) String() string {
-	return c.url
+	return redactURL(c.url)
 }
 
 func (c *client) Key() string {
-	matches := keyExtractor.FindAllStringSubmatch(c.url, 1)
-	// This is kind of an error. There should always be a / somewhere,
-	// but if you just have the raw domain you don't really need one. So
-	// we assume this is the case and return ""
-	if len(matches) == 0 {
-		return ""
+	return c.path.Key()
+}
+
+func (c *client) Path() Path {
+	return c.path.Child(nil)
+}
+
+func (c *client) IsRoot() bool {
+	return len(c.path) == 0
+}
+
+func (c *client) Parent() Reference {
+	if c.IsRoot() {
+		return nil
 	}
-	return matches[0][1]
+
+	return c.withPath(c.path[:len(c.path)-1].Child(nil))
+}
+
+func (c *client) Root() Reference {
+	return c.withPath(nil)
+}
+
+func (c *client) Ref() Reference {
+	return c.withPath(c.path)
 }
 
 func (c *client) Value(destination interface{}) error {
-	err := c.api.Call("GET", c.url, c.auth, nil, c.params, destination)
+	return c.value(nil, destination)
+}
+
+// contextAPI is implemented by Api implementations that can make calls
+// within a context. The default implementation does.
+type contextAPI interface {
+	callContext(ctx context.Context, method, path, auth string, mode AuthMode, body interface{}, params map[string]string, dest interface{}) error
+	streamContext(ctx context.Context, path, auth string, mode AuthMode, body interface{}, params map[string]string, stop <-chan bool) (<-chan RawEvent, error)
+}
+
+func (c *client) WithContext(ctx context.Context) Reference {
+	newC := c.withPath(c.path)
+	newC.ctx = ctx
+	return newC
+}
+
+func (c *client) WithAuth(auth string, mode AuthMode) Reference {
+	newC := c.withPath(c.path)
+	newC.auth = auth
+	newC.authMode = mode
+	newC.tokens = nil
+	return newC
+}
+
+// context returns the context of the client's calls.
+func (c *client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
+}
+
+// call makes a call through the client's Api, within the client's context if
+// the Api supports it.
+func (c *client) call(method, path string, body interface{}, params map[string]string, dest interface{}) error {
+	auth, err := c.token()
+	if err != nil {
+		return err
+	}
+
+	if api, ok := c.api.(contextAPI); ok {
+		return api.callContext(c.context(), method, path, auth, c.authMode, body, params, dest)
+	}
+
+	return c.api.Call(method, path, auth, body, params, dest)
+}
+
+// stream opens a stream of the client's location through the client's Api,
+// authenticated with auth, within the client's context if the Api supports
+// it.
+func (c *client) stream(auth string, params map[string]string, stop <-chan bool) (<-chan RawEvent, error) {
+	if api, ok := c.api.(contextAPI); ok {
+		return api.streamContext(c.context(), c.url, auth, c.authMode, nil, params, stop)
+	}
+
+	return c.api.Stream(c.url, auth, nil, params, stop)
+}
+
+// errAuthRevoked is the error of the auth_revoked event of watches that can't
+// reconnect with a new token.
+var errAuthRevoked = errors.New("Auth Token Revoked")
+
+const (
+	// minReconnectDelay is how long a watch waits before reconnecting after
+	// its token was revoked.
+	minReconnectDelay = 100 * time.Millisecond
+
+	// maxReconnectDelay caps the wait, which doubles with every reconnect
+	// until a stream lasts longer than it.
+	maxReconnectDelay = 10 * time.Second
+)
+
+// watchStream is one of the streams of a watch.
+type watchStream struct {
+	events <-chan RawEvent
+
+	// auth is the token the stream was opened with.
+	auth string
+
+	// hungUp is closed to close the stream before the watch is stopped.
+	hungUp   chan bool
+	hangOnce sync.Once
+}
+
+// hangUp closes the stream, if it isn't already.
+func (s *watchStream) hangUp() {
+	s.hangOnce.Do(func() { close(s.hungUp) })
+}
+
+// connect opens a stream of a watch, authenticated with auth, which is closed
+// when stop is, or when it is hung up.
+func (c *client) connect(auth string, params map[string]string, stop <-chan bool) (*watchStream, error) {
+	var err error
+	s := &watchStream{auth: auth, hungUp: make(chan bool)}
+	streamStop := make(chan bool)
+	go func() {
+		select {
+		case <-stop:
+		case <-s.hungUp:
+		}
+		close(streamStop)
+	}()
+
+	s.events, err = c.stream(auth, params, streamStop)
+	if err != nil {
+		s.hangUp()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// value GETs the value at the client's location, filtered by the given query
+// params.
+func (c *client) value(params map[string]string, destination interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	err := c.call("GET", c.url, nil, params, destination)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-var defaultUnmarshaller = func(path string, data []byte) (interface{}, error) {
-	var object map[string]interface{}
-	err := json.Unmarshal(data, &object)
-	return object, err
+func (c *client) ETag() (string, error) {
+	var etag string
+
+	err := c.value(nil, &etagDestination{etag: &etag, codec: c.codec})
+	if err != nil {
+		return "", err
+	}
+
+	return etag, nil
+}
+
+// defaultUnmarshaller returns the EventUnmarshaller used by Watch when none is
+// given. It decodes each event into a map[string]interface{} with codec.
+func defaultUnmarshaller(codec Codec) EventUnmarshaller {
+	return func(path string, data []byte) (interface{}, error) {
+		var object map[string]interface{}
+		err := codec.Unmarshal(data, &object)
+		return object, err
+	}
 }
 
-func handlePatchPut(event *StreamEvent, unmarshaller EventUnmarshaller) {
+func handlePatchPut(event *StreamEvent, unmarshaller EventUnmarshaller, codec Codec) {
 	var halfParsedData struct {
 		Path string
 		Data json.RawMessage
 	}
 
-	err := json.Unmarshal([]byte(event.RawData), &halfParsedData)
+	err := codec.Unmarshal([]byte(event.RawData), &halfParsedData)
 	if err != nil {
 		event.Error = err
 		return
@@ -144,7 +344,22 @@ func handlePatchPut(event *StreamEvent, unmarshaller EventUnmarshaller) {
 }
 
 func (c *client) Watch(unmarshaller EventUnmarshaller, stop <-chan bool) (<-chan StreamEvent, error) {
-	rawEvents, err := c.api.Stream(c.url, c.auth, nil, c.params, stop)
+	return c.watch(nil, unmarshaller, stop)
+}
+
+// watch streams changes to the client's location, filtered by the given query
+// params.
+func (c *client) watch(params map[string]string, unmarshaller EventUnmarshaller, stop <-chan bool) (<-chan StreamEvent, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	auth, err := c.token()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := c.connect(auth, params, stop)
 	if err != nil {
 		return nil, err
 	}
@@ -152,168 +367,311 @@ func (c *client) Watch(unmarshaller EventUnmarshaller, stop <-chan bool) (<-chan
 	processedEvents := make(chan StreamEvent, 1000)
 
 	if unmarshaller == nil {
-		unmarshaller = defaultUnmarshaller
+		unmarshaller = defaultUnmarshaller(c.codec)
 	}
 
 	go func() {
-		for rawEvent := range rawEvents {
-			event := StreamEvent{
-				Event:   rawEvent.Event,
-				RawData: rawEvent.Data,
-				Error:   rawEvent.Error,
+		defer close(processedEvents)
+
+		delay := minReconnectDelay
+		for {
+			opened := time.Now()
+			revoked := c.forwardEvents(stream.events, processedEvents, unmarshaller)
+			stream.hangUp()
+			if revoked == nil {
+				return
 			}
 
-			// connection error: just forward it along
-			if event.Error != nil {
-				processedEvents <- event
-				continue
+			// The stream's token was revoked: reconnect with a new one, once
+			// the old stream is done. A token source that can't give one ends
+			// the watch, like a client without a token source.
+			for range stream.events {
 			}
+			c.tokens.invalidate(stream.auth)
 
-			switch event.Event {
-			case "patch", "put":
-				handlePatchPut(&event, unmarshaller)
-				processedEvents <- event
-			case "keep-alive":
-				break
-			case "cancel":
-				event.Error = errors.New("Permission Denied")
-				processedEvents <- event
-			case "auth_revoked":
-				event.Error = errors.New("Auth Token Revoked")
-				processedEvents <- event
-				close(processedEvents)
+			auth, err := c.token()
+			if err == nil && auth == stream.auth {
+				err = errAuthRevoked
+			}
+			if err != nil {
+				revoked.Error = err
+				processedEvents <- *revoked
 				return
 			}
-		}
 
-		close(processedEvents)
+			// Back off between reconnects, in case the new tokens get revoked
+			// right away too.
+			if time.Since(opened) > maxReconnectDelay {
+				delay = minReconnectDelay
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+
+			c.metrics.StreamReconnected()
+			stream, err = c.connect(auth, params, stop)
+			if err != nil {
+				revoked.Error = err
+				processedEvents <- *revoked
+				return
+			}
+		}
 	}()
 
 	return processedEvents, nil
 }
 
-func (c *client) Shallow() Client {
-	newParams := make(map[string]string)
-	for key, value := range c.params {
-		newParams[key] = value
-	}
-	newParams["shallow"] = "true"
+// forwardEvents processes the raw events of a stream, and forwards them to
+// events until the stream closes. If it stopped early, because the stream's
+// token was revoked and the client may get a new one, it returns the
+// auth_revoked event instead of forwarding it.
+func (c *client) forwardEvents(rawEvents <-chan RawEvent, events chan<- StreamEvent, unmarshaller EventUnmarshaller) *StreamEvent {
+	for rawEvent := range rawEvents {
+		event := StreamEvent{
+			Event:   rawEvent.Event,
+			RawData: rawEvent.Data,
+			Error:   rawEvent.Error,
+		}
 
-	return &client{
-		api:    c.api,
-		auth:   c.auth,
-		url:    c.url,
-		params: newParams,
+		// connection error: just forward it along
+		if event.Error != nil {
+			events <- event
+			continue
+		}
+
+		switch event.Event {
+		case "patch", "put":
+			handlePatchPut(&event, unmarshaller, c.codec)
+			events <- event
+		case "keep-alive", "":
+			// Nothing to deliver for keep-alives, or for the end of a
+			// stream that closed cleanly.
+			break
+		case "cancel":
+			event.Error = errors.New("Permission Denied")
+			events <- event
+		case "auth_revoked":
+			if c.tokens != nil {
+				return &event
+			}
+
+			event.Error = errAuthRevoked
+			events <- event
+			return nil
+		default:
+			c.metrics.EventDropped(event.Event)
+		}
 	}
+
+	return nil
 }
 
-func (c *client) Child(path string) Client {
-	u := c.url + "/" + path
-	return &client{
-		api:    c.api,
-		auth:   c.auth,
-		url:    u,
-		params: c.params,
+func (c *client) Shallow() Query {
+	return c.query().withParam("shallow", true)
+}
+
+// Child returns a reference to the child at path. If path is not a valid
+// Firebase path, the error is reported by the first call made with the
+// returned client.
+func (c *client) Child(path string) Reference {
+	newC, err := c.child(path)
+	if err != nil {
+		newC = c.withPath(c.path)
+		newC.err = err
 	}
+
+	return newC
 }
 
+// Special orderBy properties, used to order by something other than a child.
 const (
-	KeyProp = "$key"
+	KeyProp      = "$key"
+	ValueProp    = "$value"
+	PriorityProp = "$priority"
 )
 
-// These are some shenanigans, golang. Shenanigans I say.
-func (c *client) newParamMap(key string, value interface{}) map[string]string {
-	ret := make(map[string]string, len(c.params)+1)
-	for key, value := range c.params {
-		ret[key] = value
-	}
-	jsonVal, _ := json.Marshal(value)
-	ret[key] = string(jsonVal)
-	return ret
+// Query functions. They map directly to the Firebase operations.
+// https://www.firebase.com/docs/rest/guide/retrieving-data.html#section-rest-queries
+func (c *client) OrderBy(prop string) Query {
+	return c.query().OrderBy(prop)
 }
 
-func (c *client) clientWithNewParam(key string, value interface{}) *client {
-	return &client{
-		api:    c.api,
-		auth:   c.auth,
-		url:    c.url,
-		params: c.newParamMap(key, value),
-	}
+func (c *client) OrderByKey() Query {
+	return c.query().OrderByKey()
 }
 
-// Query functions. They map directly to the Firebase operations.
-// https://www.firebase.com/docs/rest/guide/retrieving-data.html#section-rest-queries
-func (c *client) OrderBy(prop string) Client {
-	newC := c.clientWithNewParam("orderBy", prop)
-	newC.Order = prop
-	return newC
+func (c *client) OrderByValue() Query {
+	return c.query().OrderByValue()
+}
+
+func (c *client) OrderByPriority() Query {
+	return c.query().OrderByPriority()
+}
+
+func (c *client) OrderByChild(path string) Query {
+	return c.query().OrderByChild(path)
+}
+
+func (c *client) EqualTo(value interface{}) Query {
+	return c.query().EqualTo(value)
+}
+
+func (c *client) StartAt(value interface{}) Query {
+	return c.query().StartAt(value)
+}
+
+func (c *client) StartAfter(value interface{}) Query {
+	return c.query().StartAfter(value)
+}
+
+func (c *client) EndAt(value interface{}) Query {
+	return c.query().EndAt(value)
 }
 
-func (c *client) EqualTo(value interface{}) Client {
-	return c.clientWithNewParam("equalTo", value)
+func (c *client) EndBefore(value interface{}) Query {
+	return c.query().EndBefore(value)
 }
 
-func (c *client) StartAt(value interface{}) Client {
-	return c.clientWithNewParam("startAt", value)
+func (c *client) StartAtKey(value interface{}, key string) Query {
+	return c.query().StartAtKey(value, key)
 }
 
-func (c *client) EndAt(value interface{}) Client {
-	return c.clientWithNewParam("endAt", value)
+func (c *client) StartAfterKey(value interface{}, key string) Query {
+	return c.query().StartAfterKey(value, key)
 }
 
-func (c *client) LimitToFirst(limit uint) Client {
-	return c.clientWithNewParam("limitToFirst", limit)
+func (c *client) EndAtKey(value interface{}, key string) Query {
+	return c.query().EndAtKey(value, key)
 }
 
-func (c *client) LimitToLast(limit uint) Client {
-	return c.clientWithNewParam("limitToLast", limit)
+func (c *client) EndBeforeKey(value interface{}, key string) Query {
+	return c.query().EndBeforeKey(value, key)
 }
 
-func (c *client) Push(value interface{}, params map[string]string) (Client, error) {
+func (c *client) LimitToFirst(limit uint) Query {
+	return c.query().LimitToFirst(limit)
+}
+
+func (c *client) LimitToLast(limit uint) Query {
+	return c.query().LimitToLast(limit)
+}
+
+func (c *client) Push(value interface{}, params map[string]string, options ...WriteOption) (Reference, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	opts, err := newWriteOptions(params, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.silent {
+		return nil, errSilentPush
+	}
+
+	if opts.ifMatch != "" {
+		return nil, errConditionalPush
+	}
+
 	res := map[string]string{}
-	err := c.api.Call("POST", c.url, c.auth, value, params, &res)
+	err = c.call("POST", c.url, value, opts.params, &res)
 	if err != nil {
 		return nil, err
 	}
 
-	return &client{
-		api:    c.api,
-		auth:   c.auth,
-		url:    c.url + "/" + res["name"],
-		params: c.params,
-	}, nil
+	newC := c.withPath(c.path.Child(Path{res["name"]}))
+
+	// Firebase only answers a push with the new key, so the echo has to be
+	// read back.
+	if opts.echo != nil || opts.etag != nil {
+		if err := newC.value(nil, opts.destination(c.codec)); err != nil {
+			return newC, err
+		}
+	}
+
+	return newC, nil
 }
 
-func (c *client) Set(path string, value interface{}, params map[string]string) (Client, error) {
-	u := c.url + "/" + path
+func (c *client) Set(path string, value interface{}, params map[string]string, options ...WriteOption) (Reference, error) {
+	newC, err := c.child(path)
+	if err != nil {
+		return nil, err
+	}
 
-	err := c.api.Call("PUT", u, c.auth, value, params, nil)
+	opts, err := newWriteOptions(params, options)
 	if err != nil {
 		return nil, err
 	}
 
-	return &client{
-		api:    c.api,
-		auth:   c.auth,
-		url:    u,
-		params: c.params,
-	}, nil
+	err = c.call("PUT", newC.url, value, opts.params, opts.destination(c.codec))
+	if err != nil {
+		return nil, err
+	}
+
+	return newC, nil
 }
 
-func (c *client) Update(path string, value interface{}, params map[string]string) error {
-	err := c.api.Call("PATCH", c.url+"/"+path, c.auth, value, params, nil)
+func (c *client) SetAndGet(path string, value, destination interface{}, options ...WriteOption) (Reference, string, error) {
+	var etag string
+
+	options = append(options[:len(options):len(options)], Echo(destination), ETag(&etag))
+
+	ref, err := c.Set(path, value, nil, options...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return ref, etag, nil
+}
+
+func (c *client) Update(path string, value interface{}, params map[string]string, options ...WriteOption) error {
+	newC, err := c.child(path)
+	if err != nil {
+		return err
+	}
+
+	opts, err := newWriteOptions(params, options)
+	if err != nil {
+		return err
+	}
+
+	err = c.call("PATCH", newC.url, value, opts.params, opts.destination(c.codec))
 	return err
 }
 
-func (c *client) Remove(path string, params map[string]string) error {
-	err := c.api.Call("DELETE", c.url+"/"+path, c.auth, nil, params, nil)
+func (c *client) Remove(path string, params map[string]string, options ...WriteOption) error {
+	newC, err := c.child(path)
+	if err != nil {
+		return err
+	}
+
+	opts, err := newWriteOptions(params, options)
+	if err != nil {
+		return err
+	}
+
+	err = c.call("DELETE", newC.url, nil, opts.params, opts.destination(c.codec))
 
 	return err
 }
 
+// rulesURL is the location of the database's security rules. Rules always
+// live under the database root, no matter which location the client
+// refers to.
+func (c *client) rulesURL() string {
+	return c.root + "/.settings/rules"
+}
+
 func (c *client) Rules(params map[string]string) (*Rules, error) {
 	res := &Rules{}
-	err := c.api.Call("GET", c.url+"/.settings/rules", c.auth, nil, params, res)
+	err := c.call("GET", c.rulesURL(), nil, params, res)
 	if err != nil {
 		return nil, err
 	}
@@ -322,7 +680,7 @@ func (c *client) Rules(params map[string]string) (*Rules, error) {
 }
 
 func (c *client) SetRules(rules *Rules, params map[string]string) error {
-	err := c.api.Call("PUT", c.url+"/.settings/rules", c.auth, rules, params, nil)
+	err := c.call("PUT", c.rulesURL(), rules, params, nil)
 
 	return err
 }
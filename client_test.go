@@ -58,9 +58,66 @@ var _ = Describe("Transforming client urls/queries", func() {
 		Expect(child.url).To(Equal(testURL + "/child"))
 	})
 
-	It("Sets a query string param to ask for a shallow object", func() {
-		shallow, isClient := c.Shallow().(*client)
+	It("Escapes each key of the child path", func() {
+		child, isClient := c.Child("users/jane doe/#1").(*client)
+		Expect(isClient).To(BeTrue())
+		Expect(child.err).To(HaveOccurred())
+
+		child, isClient = c.Child("users/jane doe/100%").(*client)
+		Expect(isClient).To(BeTrue())
+		Expect(child.err).To(BeNil())
+		Expect(child.url).To(Equal(testURL + "/users/jane%20doe/100%25"))
+		Expect(child.Key()).To(Equal("100%"))
+	})
+
+	It("Splits the database root from the path of the client", func() {
+		nested, isClient := NewClient(testURL+"/a/b/", testAuth, nil).(*client)
+		Expect(isClient).To(BeTrue())
+
+		Expect(nested.root).To(Equal(testURL))
+		Expect(nested.path).To(Equal(Path{"a", "b"}))
+		Expect(nested.Key()).To(Equal("b"))
+	})
+
+	It("Navigates up the tree", func() {
+		child := c.Child("a/b/c")
+		Expect(child.Path()).To(Equal(Path{"a", "b", "c"}))
+		Expect(child.IsRoot()).To(BeFalse())
+
+		parent, isClient := child.Parent().(*client)
 		Expect(isClient).To(BeTrue())
+		Expect(parent.url).To(Equal(testURL + "/a/b"))
+		Expect(parent.Parent().Parent().IsRoot()).To(BeTrue())
+		Expect(parent.Parent().Parent().Parent()).To(BeNil())
+
+		root, isClient := child.Root().(*client)
+		Expect(isClient).To(BeTrue())
+		Expect(root.url).To(Equal(testURL + "/"))
+		Expect(root.IsRoot()).To(BeTrue())
+	})
+
+	It("Drops query params when navigating back to a reference", func() {
+		query := c.Child("a/b").OrderBy("field").LimitToFirst(5)
+
+		ref, isClient := query.Ref().(*client)
+		Expect(isClient).To(BeTrue())
+		Expect(ref.url).To(Equal(testURL + "/a/b"))
+		Expect(ref.Parent().Path()).To(Equal(Path{"a"}))
+	})
+
+	It("Does not share the path with the returned references", func() {
+		child := c.Child("a/b")
+		path := child.Path()
+		path[0] = "z"
+
+		Expect(child.Path()).To(Equal(Path{"a", "b"}))
+		Expect(child.Parent().Child("x").Path()).To(Equal(Path{"a", "x"}))
+		Expect(child.Path()).To(Equal(Path{"a", "b"}))
+	})
+
+	It("Sets a query string param to ask for a shallow object", func() {
+		shallow, isQuery := c.Shallow().(*query)
+		Expect(isQuery).To(BeTrue())
 
 		Expect(shallow.params["shallow"]).To(Equal("true"))
 	})
@@ -72,8 +129,8 @@ var _ = Describe("Transforming client urls/queries", func() {
 	})
 
 	It("Sets a field to order the results by", func() {
-		orderByClient, isClient := c.OrderBy("field").(*client)
-		Expect(isClient).To(BeTrue())
+		orderByClient, isQuery := c.OrderBy("field").(*query)
+		Expect(isQuery).To(BeTrue())
 
 		expectedParams := map[string]string{
 			"orderBy": `"field"`,
@@ -82,8 +139,8 @@ var _ = Describe("Transforming client urls/queries", func() {
 	})
 
 	It("Queries for records whose field == true", func() {
-		equalClient, isClient := c.OrderBy("field").EqualTo(true).(*client)
-		Expect(isClient).To(BeTrue())
+		equalClient, isQuery := c.OrderBy("field").EqualTo(true).(*query)
+		Expect(isQuery).To(BeTrue())
 
 		expectedParams := map[string]string{
 			"orderBy": `"field"`,
@@ -93,8 +150,8 @@ var _ = Describe("Transforming client urls/queries", func() {
 	})
 
 	It("Queries ranges of fields", func() {
-		rangeClient, isClient := c.OrderBy("field").StartAt(0).EndAt(5).(*client)
-		Expect(isClient).To(BeTrue())
+		rangeClient, isQuery := c.OrderBy("field").StartAt(0).EndAt(5).(*query)
+		Expect(isQuery).To(BeTrue())
 
 		expectedParams := map[string]string{
 			"orderBy": `"field"`,
@@ -105,8 +162,8 @@ var _ = Describe("Transforming client urls/queries", func() {
 	})
 
 	It("Limits query results to first 10 children", func() {
-		limitClient, isClient := c.OrderBy("field").LimitToFirst(5).(*client)
-		Expect(isClient).To(BeTrue())
+		limitClient, isQuery := c.OrderBy("field").LimitToFirst(5).(*query)
+		Expect(isQuery).To(BeTrue())
 
 		expectedParams := map[string]string{
 			"orderBy":      `"field"`,
@@ -116,8 +173,8 @@ var _ = Describe("Transforming client urls/queries", func() {
 	})
 
 	It("Limits query results to last 10 children", func() {
-		limitClient, isClient := c.OrderBy("field").LimitToLast(5).(*client)
-		Expect(isClient).To(BeTrue())
+		limitClient, isQuery := c.OrderBy("field").LimitToLast(5).(*query)
+		Expect(isQuery).To(BeTrue())
 
 		expectedParams := map[string]string{
 			"orderBy":     `"field"`,
@@ -278,6 +335,33 @@ var _ = Describe("Manipulating values from firebase", func() {
 		})
 	})
 
+	Context("Using an invalid path", func() {
+		var requests int
+
+		BeforeEach(func() {
+			requests = 0
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				requests++
+			}
+		})
+
+		It("Fails before making any request", func() {
+			err := testClient.Child("a.b").Value(&testResource)
+			Expect(err).To(BeAssignableToTypeOf(&InvalidPathError{}))
+
+			_, err = testClient.Set("a/$b", &testResource, nil)
+			Expect(err).To(BeAssignableToTypeOf(&InvalidPathError{}))
+
+			err = testClient.Update("[a]", &testResource, nil)
+			Expect(err).To(BeAssignableToTypeOf(&InvalidPathError{}))
+
+			err = testClient.Child("ok").Remove("a#b", nil)
+			Expect(err).To(BeAssignableToTypeOf(&InvalidPathError{}))
+
+			Expect(requests).To(Equal(0))
+		})
+	})
+
 	Context("Reading the security rules", func() {
 		var testRules Rules = make(map[string]interface{})
 
@@ -587,6 +671,10 @@ var _ = Describe("Firebase timestamps", func() {
 })
 
 func TestFirebase(t *testing.T) {
+	// Many specs make calls fail on purpose; don't wait between their
+	// retries.
+	minRetryDelay, maxRetryDelay = time.Millisecond, time.Millisecond
+
 	RegisterFailHandler(Fail)
 	junitReporter := reporters.NewJUnitReporter("junit.xml")
 	RunSpecsWithDefaultAndCustomReporters(t, "Firebase Suite",
@@ -0,0 +1,73 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Codec marshals the values sent to Firebase and unmarshals the values read
+// from it. The default codec is JSONCodec{}, which uses encoding/json. A
+// different codec can be given to NewClient with the WithCodec option, e.g.
+// to decode numbers without losing precision, or to plug in a faster JSON
+// library.
+type Codec interface {
+	// Marshal returns the JSON encoding of v.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes the JSON encoded data into v.
+	Unmarshal(data []byte, v interface{}) error
+
+	// NewDecoder returns a decoder that reads JSON values from r.
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Decoder reads and decodes JSON values from a stream. *json.Decoder
+// satisfies it.
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// JSONCodec is the Codec backed by encoding/json.
+type JSONCodec struct {
+	// UseNumber makes numbers decoded into an interface{} a json.Number
+	// instead of a float64. Integers larger than 2^53 (such as int64 IDs)
+	// lose precision as a float64.
+	UseNumber bool
+}
+
+func (c JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	if !c.UseNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+
+	// Like json.Unmarshal, reject anything after the value.
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("firebase: invalid data after top-level JSON value")
+	}
+
+	return nil
+}
+
+func (c JSONCodec) NewDecoder(r io.Reader) Decoder {
+	decoder := json.NewDecoder(r)
+	if c.UseNumber {
+		decoder.UseNumber()
+	}
+
+	return decoder
+}
+
+// defaultCodec is used when no codec is configured.
+var defaultCodec Codec = JSONCodec{}
@@ -0,0 +1,136 @@
+package firebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingCodec is a JSONCodec that counts how many values it marshals.
+type countingCodec struct {
+	JSONCodec
+	marshalled int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshalled++
+	return c.JSONCodec.Marshal(v)
+}
+
+var _ = Describe("JSON codecs", func() {
+	var (
+		testServer *httptest.Server
+		handler    func(w http.ResponseWriter, r *http.Request)
+	)
+
+	BeforeEach(func() {
+		testServer = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				handler(w, r)
+			}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("Keeps the precision of large integers with UseNumber", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id": 9007199254740993}`)
+		}
+
+		var lossy, exact map[string]interface{}
+
+		Expect(NewClient(testServer.URL, "", nil).Value(&lossy)).To(Succeed())
+		Expect(lossy["id"]).To(Equal(float64(9007199254740992)))
+
+		c := NewClient(testServer.URL, "", nil, WithCodec(JSONCodec{UseNumber: true}))
+		Expect(c.Value(&exact)).To(Succeed())
+		Expect(exact["id"]).To(Equal(json.Number("9007199254740993")))
+	})
+
+	It("Decodes ordered children and exported nodes with the codec", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"a": {"id": 9007199254740993, ".priority": 9007199254740993}}`)
+		}
+
+		c := NewClient(testServer.URL, "", nil, WithCodec(JSONCodec{UseNumber: true}))
+
+		children, err := c.Children()
+		Expect(err).To(BeNil())
+		var child map[string]interface{}
+		Expect(children[0].Unmarshal(&child)).To(Succeed())
+		Expect(child["id"]).To(Equal(json.Number("9007199254740993")))
+
+		var node Node
+		Expect(c.ValueExport(&node)).To(Succeed())
+		var value map[string]map[string]interface{}
+		Expect(node.Unmarshal(&value)).To(Succeed())
+		Expect(value["a"]["id"]).To(Equal(json.Number("9007199254740993")))
+
+		exported, err := json.Marshal(&node)
+		Expect(err).To(BeNil())
+		Expect(exported).To(MatchJSON(`{"a": {"id": 9007199254740993, ".priority": 9007199254740993}}`))
+	})
+
+	It("Decodes echoes for Api implementations without ETags with the codec", func() {
+		var echo map[string]interface{}
+		var etag string
+		dest := &etagDestination{destination: &echo, etag: &etag, codec: JSONCodec{UseNumber: true}}
+
+		Expect(json.Unmarshal([]byte(`{"id": 9007199254740993}`), dest)).To(Succeed())
+		Expect(echo["id"]).To(Equal(json.Number("9007199254740993")))
+	})
+
+	It("Decodes watched events with the codec", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "event: put")
+			fmt.Fprintln(w, `data: {"path": "/", "data": {"id": 9007199254740993}}`)
+		}
+
+		c := NewClient(testServer.URL, "", nil, WithCodec(JSONCodec{UseNumber: true}))
+		stop := make(chan bool)
+		defer close(stop)
+
+		events, err := c.Watch(nil, stop)
+		Expect(err).To(BeNil())
+
+		var event StreamEvent
+		Eventually(events).Should(Receive(&event))
+		Expect(event.Resource).To(Equal(map[string]interface{}{
+			"id": json.Number("9007199254740993"),
+		}))
+	})
+
+	It("Marshals request bodies with the codec", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(Equal(`{"A":1}`))
+		}
+
+		codec := &countingCodec{}
+		c := NewClient(testServer.URL, "", nil, WithCodec(codec))
+		Expect(c.Update("widget", Widget{A: 1}, nil)).To(Succeed())
+		Expect(codec.marshalled).To(Equal(1))
+	})
+
+	It("Rejects trailing data like json.Unmarshal", func() {
+		var v interface{}
+		Expect(JSONCodec{UseNumber: true}.Unmarshal([]byte(`1 2`), &v)).NotTo(Succeed())
+		Expect(JSONCodec{UseNumber: true}.Unmarshal([]byte(`12`), &v)).To(Succeed())
+		Expect(v).To(Equal(json.Number("12")))
+	})
+
+	It("Unmarshals timestamps re-encoded as floats", func() {
+		var ts ServerTimestamp
+		Expect(json.Unmarshal([]byte(`1.7e+12`), &ts)).To(Succeed())
+		Expect(time.Time(ts).Equal(time.Unix(1700000000, 0))).To(BeTrue())
+	})
+})
@@ -0,0 +1,199 @@
+package firebase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// customTokenAudience is the audience of custom tokens: the Identity Toolkit,
+// which exchanges them for ID tokens.
+const customTokenAudience = "https://identitytoolkit.googleapis.com/google.identity.identitytoolkit.v1.IdentityToolkit"
+
+// customTokenLifetime is how long a custom token is valid, the longest
+// Firebase accepts.
+const customTokenLifetime = time.Hour
+
+const (
+	// maxUIDLength is the longest uid of a custom token.
+	maxUIDLength = 128
+
+	// maxClaimsSize is the largest size of the developer claims of a custom
+	// token, once encoded.
+	maxClaimsSize = 1000
+)
+
+// reservedClaims are the claim names of JWTs and Firebase ID tokens, which
+// the developer claims of custom tokens can't use.
+var reservedClaims = map[string]bool{
+	"acr": true, "amr": true, "at_hash": true, "aud": true, "auth_time": true,
+	"azp": true, "cnf": true, "c_hash": true, "exp": true, "firebase": true,
+	"iat": true, "iss": true, "jti": true, "nbf": true, "nonce": true, "sub": true,
+}
+
+// customTokenClaims are the claims of a custom token.
+type customTokenClaims struct {
+	Issuer   string                 `json:"iss"`
+	Subject  string                 `json:"sub"`
+	Audience string                 `json:"aud"`
+	IssuedAt int64                  `json:"iat"`
+	Expiry   int64                  `json:"exp"`
+	UID      string                 `json:"uid"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+}
+
+// MintCustomToken returns a Firebase custom token for the user uid, signed
+// with RS256 by serviceAccount. The token's developer claims are available to
+// security rules as auth.token; they can't use the reserved names of JWTs and
+// ID tokens. The token is valid for an hour.
+//
+// A custom token is not a database token: clients sign in with it (e.g. with
+// signInWithCustomToken), and are given an ID token to use as their auth.
+func MintCustomToken(uid string, claims map[string]interface{}, serviceAccount ServiceAccountKey) (string, error) {
+	if uid == "" || len(uid) > maxUIDLength {
+		return "", fmt.Errorf("firebase: a custom token's uid must have 1 to %d characters", maxUIDLength)
+	}
+	if serviceAccount.ClientEmail == "" {
+		return "", errNoClientEmail
+	}
+
+	for name := range claims {
+		if reservedClaims[name] {
+			return "", fmt.Errorf("firebase: %q is a reserved claim name", name)
+		}
+	}
+
+	if len(claims) > 0 {
+		encoded, err := json.Marshal(claims)
+		if err != nil {
+			return "", err
+		}
+		if len(encoded) > maxClaimsSize {
+			return "", fmt.Errorf("firebase: a custom token's claims must not exceed %d bytes once encoded", maxClaimsSize)
+		}
+	}
+
+	privateKey, err := parsePrivateKey(serviceAccount.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return signRS256(customTokenClaims{
+		Issuer:   serviceAccount.ClientEmail,
+		Subject:  serviceAccount.ClientEmail,
+		Audience: customTokenAudience,
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(customTokenLifetime).Unix(),
+		UID:      uid,
+		Claims:   claims,
+	}, privateKey, "")
+}
+
+const (
+	// maxLegacyUIDLength is the longest uid of a legacy token.
+	maxLegacyUIDLength = 256
+
+	// maxLegacyTokenLength is the longest legacy token Firebase accepts.
+	maxLegacyTokenLength = 1024
+)
+
+// errLegacyNoUID is returned for legacy tokens that are neither an admin's
+// nor a user's.
+var errLegacyNoUID = errors.New("firebase: a legacy token's data must have a string uid, unless it is an admin token")
+
+// errLegacyNoSecret is returned for legacy tokens without a secret to sign
+// them.
+var errLegacyNoSecret = errors.New("firebase: a legacy token needs a database secret")
+
+// legacyTokenClaims are the claims of a legacy token.
+type legacyTokenClaims struct {
+	Version   int                    `json:"v"`
+	IssuedAt  int64                  `json:"iat"`
+	Data      map[string]interface{} `json:"d"`
+	Expiry    int64                  `json:"exp,omitempty"`
+	NotBefore int64                  `json:"nbf,omitempty"`
+	Admin     bool                   `json:"admin,omitempty"`
+	Debug     bool                   `json:"debug,omitempty"`
+}
+
+// LegacyTokenOption configures a token minted by MintLegacyToken.
+type LegacyTokenOption func(*legacyTokenClaims)
+
+// LegacyExpiry makes the token expire at expiry, instead of 24 hours after
+// it is minted.
+func LegacyExpiry(expiry time.Time) LegacyTokenOption {
+	return func(claims *legacyTokenClaims) {
+		claims.Expiry = expiry.Unix()
+	}
+}
+
+// LegacyNotBefore makes the token invalid until notBefore.
+func LegacyNotBefore(notBefore time.Time) LegacyTokenOption {
+	return func(claims *legacyTokenClaims) {
+		claims.NotBefore = notBefore.Unix()
+	}
+}
+
+// LegacyAdmin makes the token bypass security rules, like the secret itself.
+func LegacyAdmin() LegacyTokenOption {
+	return func(claims *legacyTokenClaims) {
+		claims.Admin = true
+	}
+}
+
+// LegacyDebug makes Firebase answer the token's requests with the details of
+// its security rules evaluation.
+func LegacyDebug() LegacyTokenOption {
+	return func(claims *legacyTokenClaims) {
+		claims.Debug = true
+	}
+}
+
+// MintLegacyToken returns a token signed with HS256 by a legacy database
+// secret, in the format of Firebase's token generator. Its data is available
+// to security rules as auth, and must have a string uid unless the token is
+// an admin's. Unlike the secret, the token is limited to what the rules grant
+// its data, and can be given to NewClient:
+//
+//	token, err := firebase.MintLegacyToken(secret, map[string]interface{}{"uid": "jane"})
+//	client := firebase.NewClient(url, token, nil)
+func MintLegacyToken(secret string, data map[string]interface{}, options ...LegacyTokenOption) (string, error) {
+	if secret == "" {
+		return "", errLegacyNoSecret
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	now := time.Now()
+	claims := legacyTokenClaims{
+		IssuedAt: now.Unix(),
+		Data:     data,
+		Expiry:   now.Add(24 * time.Hour).Unix(),
+	}
+
+	for _, option := range options {
+		option(&claims)
+	}
+
+	uid, isString := data["uid"].(string)
+	if !claims.Admin && (!isString || uid == "") {
+		return "", errLegacyNoUID
+	}
+	if len(uid) > maxLegacyUIDLength {
+		return "", fmt.Errorf("firebase: a legacy token's uid must not exceed %d characters", maxLegacyUIDLength)
+	}
+
+	token, err := signHS256(claims, secret)
+	if err != nil {
+		return "", err
+	}
+	if len(token) > maxLegacyTokenLength {
+		return "", fmt.Errorf("firebase: a legacy token must not exceed %d characters, its data is too large", maxLegacyTokenLength)
+	}
+
+	return token, nil
+}
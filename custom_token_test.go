@@ -0,0 +1,155 @@
+package firebase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// verifyHS256 checks the signature of a JWT, and returns its claims.
+func verifyHS256(token, secret string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	Expect(parts).To(HaveLen(3))
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	Expect(parts[2]).To(Equal(base64.RawURLEncoding.EncodeToString(mac.Sum(nil))))
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	Expect(err).To(BeNil())
+	Expect(string(header)).To(Equal(`{"alg":"HS256","typ":"JWT"}`))
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	Expect(err).To(BeNil())
+
+	var claims map[string]interface{}
+	Expect(json.Unmarshal(payload, &claims)).To(Succeed())
+	return claims
+}
+
+var _ = Describe("Custom tokens", func() {
+	var (
+		key            ServiceAccountKey
+		serviceAccount *ServiceAccountTokenSource
+	)
+
+	BeforeEach(func() {
+		keyJSON, _ := testServiceAccountKey("")
+		var err error
+		serviceAccount, err = NewServiceAccountTokenSource(keyJSON)
+		Expect(err).To(BeNil())
+		key = serviceAccount.Key()
+	})
+
+	It("Mints RS256 tokens for a user", func() {
+		token, err := MintCustomToken("jane", map[string]interface{}{"premium": true}, key)
+		Expect(err).To(BeNil())
+
+		header, claims := verifyRS256(token, &serviceAccount.privateKey.PublicKey)
+		Expect(header).To(Equal(map[string]interface{}{"alg": "RS256", "typ": "JWT"}))
+		Expect(claims["iss"]).To(Equal(key.ClientEmail))
+		Expect(claims["sub"]).To(Equal(key.ClientEmail))
+		Expect(claims["aud"]).To(Equal(
+			"https://identitytoolkit.googleapis.com/google.identity.identitytoolkit.v1.IdentityToolkit"))
+		Expect(claims["uid"]).To(Equal("jane"))
+		Expect(claims["claims"]).To(Equal(map[string]interface{}{"premium": true}))
+		Expect(claims["exp"].(float64) - claims["iat"].(float64)).To(BeEquivalentTo(3600))
+	})
+
+	It("Leaves out empty claims", func() {
+		token, err := MintCustomToken("jane", nil, key)
+		Expect(err).To(BeNil())
+
+		_, claims := verifyRS256(token, &serviceAccount.privateKey.PublicKey)
+		Expect(claims).NotTo(HaveKey("claims"))
+	})
+
+	It("Rejects reserved claims, bad uids and large claims", func() {
+		_, err := MintCustomToken("jane", map[string]interface{}{"firebase": 1}, key)
+		Expect(err).To(MatchError(ContainSubstring(`"firebase" is a reserved claim name`)))
+
+		_, err = MintCustomToken("", nil, key)
+		Expect(err).To(HaveOccurred())
+		_, err = MintCustomToken(strings.Repeat("a", 129), nil, key)
+		Expect(err).To(HaveOccurred())
+
+		_, err = MintCustomToken("jane", map[string]interface{}{"bio": strings.Repeat("a", 1000)}, key)
+		Expect(err).To(MatchError(ContainSubstring("1000 bytes")))
+
+		_, err = MintCustomToken("jane", nil, ServiceAccountKey{PrivateKey: key.PrivateKey})
+		Expect(err).To(Equal(errNoClientEmail))
+	})
+})
+
+var _ = Describe("Legacy tokens", func() {
+	const secret = "database-secret"
+
+	It("Mints HS256 tokens in the token generator's format", func() {
+		expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+		token, err := MintLegacyToken(secret, map[string]interface{}{"uid": "jane", "team": "red"},
+			LegacyExpiry(expiry), LegacyDebug())
+		Expect(err).To(BeNil())
+
+		claims := verifyHS256(token, secret)
+		Expect(claims["v"]).To(BeEquivalentTo(0))
+		Expect(claims["d"]).To(Equal(map[string]interface{}{"uid": "jane", "team": "red"}))
+		Expect(claims["exp"]).To(BeEquivalentTo(expiry.Unix()))
+		Expect(claims["debug"]).To(BeTrue())
+		Expect(claims).NotTo(HaveKey("admin"))
+		Expect(claims).NotTo(HaveKey("nbf"))
+	})
+
+	It("Expires tokens after a day by default", func() {
+		token, err := MintLegacyToken(secret, map[string]interface{}{"uid": "jane"})
+		Expect(err).To(BeNil())
+
+		claims := verifyHS256(token, secret)
+		Expect(claims["exp"].(float64) - claims["iat"].(float64)).To(BeEquivalentTo(24 * 3600))
+	})
+
+	It("Needs a uid unless the token is an admin's", func() {
+		_, err := MintLegacyToken(secret, nil)
+		Expect(err).To(Equal(errLegacyNoUID))
+		_, err = MintLegacyToken(secret, map[string]interface{}{"uid": 1})
+		Expect(err).To(Equal(errLegacyNoUID))
+
+		token, err := MintLegacyToken(secret, nil, LegacyAdmin())
+		Expect(err).To(BeNil())
+		Expect(verifyHS256(token, secret)["admin"]).To(BeTrue())
+	})
+
+	It("Rejects long uids, large tokens and missing secrets", func() {
+		_, err := MintLegacyToken(secret, map[string]interface{}{"uid": strings.Repeat("a", 257)})
+		Expect(err).To(HaveOccurred())
+
+		_, err = MintLegacyToken(secret, map[string]interface{}{"uid": "jane", "bio": strings.Repeat("a", 1000)})
+		Expect(err).To(MatchError(ContainSubstring("1024 characters")))
+
+		_, err = MintLegacyToken("", map[string]interface{}{"uid": "jane"})
+		Expect(err).To(Equal(errLegacyNoSecret))
+	})
+
+	It("Can be given to NewClient", func() {
+		var auth string
+		database := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.URL.Query().Get("auth")
+			w.Write([]byte(`{"A":1}`))
+		}))
+		defer database.Close()
+
+		token, err := MintLegacyToken(secret, map[string]interface{}{"uid": "jane"})
+		Expect(err).To(BeNil())
+
+		var w Widget
+		Expect(NewClient(database.URL, token, nil).Value(&w)).To(Succeed())
+		Expect(verifyHS256(auth, secret)["d"]).To(Equal(map[string]interface{}{"uid": "jane"}))
+	})
+})
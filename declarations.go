@@ -1,5 +1,11 @@
 package firebase
 
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
 // Rules is the structure for security rules.
 type Rules map[string]interface{}
 
@@ -9,17 +15,91 @@ type Rules map[string]interface{}
 // as an interface{}, or an error is returned if the unmarshal fails.
 type EventUnmarshaller func(path string, data []byte) (interface{}, error)
 
-type Client interface {
+// Client is the original name of Reference. It is kept so that existing code
+// that refers to Client keeps compiling.
+type Client = Reference
+
+// Reference refers to a location in a Firebase database. It is used to read,
+// watch and write the value at that location. Queries built from a Reference
+// (OrderBy, Shallow, etc.) return a read-only Query instead.
+type Reference interface {
 	// String returns the absolute URL path for the client
 	String() string
 
 	// Key returns the last part of the URL path for the client.
 	Key() string
 
+	// Path returns the location of the client relative to the database root.
+	Path() Path
+
+	// IsRoot reports whether the client refers to the database root.
+	IsRoot() bool
+
+	// Parent returns a reference to the parent location of the client, or nil
+	// if the client refers to the database root.
+	Parent() Reference
+
+	// Root returns a reference to the database root.
+	Root() Reference
+
+	// Ref returns a reference to the same location as the client.
+	Ref() Reference
+
+	// WithContext returns a reference to the same location, whose calls
+	// and watches are made within ctx: they are canceled along with it, and
+	// traced as children of its span (see WithTracer). Queries built from
+	// the returned reference use ctx too.
+	WithContext(ctx context.Context) Reference
+
+	// WithAuth returns a reference to the same location, whose calls and
+	// watches send auth as mode says, instead of the client's token. Queries
+	// built from the returned reference use it too.
+	WithAuth(auth string, mode AuthMode) Reference
+
 	// Value GETs the value referenced by the client and unmarshals it into
 	// the passed in destination.
 	Value(destination interface{}) error
 
+	// ETag returns the ETag of the value referenced by the client, which
+	// changes whenever the value does. The value itself is read, but
+	// discarded.
+	// https://firebase.google.com/docs/database/rest/save-data#section-conditional-requests
+	ETag() (string, error)
+
+	// Children GETs the children of the referenced location, sorted in
+	// Firebase's default order (by priority, then by key). They are read in
+	// the export format, so each child comes with its Priority.
+	Children() ([]KeyValue, error)
+
+	// ValueOrdered GETs the children of the referenced location, in the same
+	// order as Children, and unmarshals each child's value into a new
+	// element of the slice that destination points to.
+	ValueOrdered(destination interface{}) error
+
+	// ValueRaw GETs the value referenced by the client and returns its JSON
+	// encoding, exactly as Firebase sent it.
+	ValueRaw() (json.RawMessage, error)
+
+	// ValueReader GETs the value referenced by the client and returns the
+	// response body, without reading it. The caller must close it.
+	ValueReader() (io.ReadCloser, error)
+
+	// ValueExport GETs the value referenced by the client in Firebase's export
+	// format, which includes the priorities of all of its values, and
+	// decodes it into destination.
+	ValueExport(destination *Node) error
+
+	// ValueStream GETs the value referenced by the client and calls callback
+	// with each of its children as they are decoded from the response, so
+	// that very large locations can be read with bounded memory. Returning
+	// ErrStopStream from callback stops reading early; any other error is
+	// returned by ValueStream.
+	ValueStream(callback ChildCallback) error
+
+	// Iterate returns an Iterator that reads the children of the referenced
+	// location in pages of pageSize children, instead of all at once.
+	Iterate(pageSize uint) *Iterator
+
 	// Watch streams changes to the Client's path in real-time, in a separate
 	// goroutine.
 	//
@@ -43,39 +123,86 @@ type Client interface {
 	// Shallow returns a list of keys at a particular location
 	// Only supports objects, unlike the REST artument which supports
 	// literals. If the location is a literal, use Client#Value()
-	Shallow() Client
+	Shallow() Query
 
 	// Child returns a reference to the child specified by `path`. This does not
 	// actually make a request to firebase, but you can then manipulate the reference
 	// by calling one of the other methods (such as `Value`, `Update`, or `Set`).
-	Child(path string) Client
+	//
+	// Each key of `path` is validated against Firebase's key rules and URL
+	// escaped. An invalid path is reported as an *InvalidPathError by the
+	// first call made with the returned reference.
+	Child(path string) Reference
 
-	// Query functions. They map directly to the Firebase operations.
+	// Query functions. They map directly to the Firebase operations, and
+	// return a read-only Query. See Query for their documentation.
 	// https://www.firebase.com/docs/rest/guide/retrieving-data.html#section-rest-queries
-	OrderBy(prop string) Client
-	EqualTo(value interface{}) Client
-	StartAt(value interface{}) Client
-	EndAt(value interface{}) Client
-	LimitToFirst(limit uint) Client
-	LimitToLast(limit uint) Client
+	OrderBy(prop string) Query
+	OrderByKey() Query
+	OrderByValue() Query
+	OrderByPriority() Query
+	OrderByChild(path string) Query
+	EqualTo(value interface{}) Query
+	StartAt(value interface{}) Query
+	StartAfter(value interface{}) Query
+	EndAt(value interface{}) Query
+	EndBefore(value interface{}) Query
+	StartAtKey(value interface{}, key string) Query
+	StartAfterKey(value interface{}, key string) Query
+	EndAtKey(value interface{}, key string) Query
+	EndBeforeKey(value interface{}, key string) Query
+	LimitToFirst(limit uint) Query
+	LimitToLast(limit uint) Query
+
+	// Push, Set and Update marshal `value` to JSON, unless it is an
+	// io.Reader or a json.RawMessage, whose bytes are sent as they are. A
+	// request whose body is an io.Reader is only retried if the reader is
+	// also an io.Seeker; the body is sent again from where it started.
+	//
+	// All writes accept WriteOptions, such as Silent, WriteSizeLimit and
+	// Timeout. Push cannot be Silent, since Firebase's response carries the
+	// key of the new child. Echo and ETag return what Firebase stored, with
+	// server values resolved.
 
 	// Creates a new value under this reference.
 	// Returns a reference to the newly created value.
 	// https://www.firebase.com/docs/web/api/firebase/push.html
-	Push(value interface{}, params map[string]string) (Client, error)
+	Push(value interface{}, params map[string]string, options ...WriteOption) (Reference, error)
+
+	// PushIdempotent is like Push, but generates the key of the new value
+	// with GeneratePushID and writes it with a PUT, so that retried requests
+	// can't create duplicates. Unlike Push, it can be Silent.
+	PushIdempotent(value interface{}, params map[string]string, options ...WriteOption) (Reference, error)
 
 	// Overwrites the value at the specified path and returns a reference
-	// that points to the path specified by `path`
-	Set(path string, value interface{}, params map[string]string) (Client, error)
+	// that points to the path specified by `path`. Set, Update and Remove
+	// return an *InvalidPathError without contacting Firebase if `path` is
+	// not a valid Firebase path.
+	Set(path string, value interface{}, params map[string]string, options ...WriteOption) (Reference, error)
+
+	// SetAndGet is like Set, but also decodes the value Firebase stored into
+	// `destination`, with server values such as ServerTime resolved, and
+	// returns the stored value's ETag.
+	SetAndGet(path string, value, destination interface{}, options ...WriteOption) (Reference, string, error)
+
+	// SetWithPriority overwrites the value at the specified path along with
+	// its priority, and returns a reference to it. A priority must be nil, a
+	// number or a string.
+	// https://firebase.google.com/docs/database/rest/app-management#section-priorities
+	SetWithPriority(path string, value, priority interface{}, options ...WriteOption) (Reference, error)
+
+	// SetPriority changes the priority of the referenced value, leaving the
+	// value itself untouched.
+	SetPriority(priority interface{}) error
 
 	// Update performs a partial update with the given value at the specified path.
 	// Returns an error if the update could not be performed.
 	// https://www.firebase.com/docs/web/api/firebase/update.html
-	Update(path string, value interface{}, params map[string]string) error
+	Update(path string, value interface{}, params map[string]string, options ...WriteOption) error
 
 	// Remove deletes the data at the current reference.
 	// https://www.firebase.com/docs/web/api/firebase/remove.html
-	Remove(path string, params map[string]string) error
+	Remove(path string, params map[string]string, options ...WriteOption) error
 
 	// Rules returns the security rules for the database.
 	// https://www.firebase.com/docs/rest/api/#section-security-rules
@@ -86,6 +213,92 @@ type Client interface {
 	SetRules(rules *Rules, params map[string]string) error
 }
 
+// Query is a read-only view of a location, filtered and ordered by the query
+// functions. It cannot be written to; use Ref to get back to the writable
+// Reference.
+//
+// Queries are validated as they are built: a range filter (StartAt, EndAt or
+// EqualTo) requires OrderBy to have been called first, EqualTo cannot be
+// combined with StartAt or EndAt, and only one limit may be set. An invalid
+// query is reported as an *InvalidQueryError by Value or Watch.
+type Query interface {
+	// String returns the absolute URL path of the queried location.
+	String() string
+
+	// Ref returns a reference to the queried location, without any of the
+	// query's parameters.
+	Ref() Reference
+
+	// Value GETs the query's results and unmarshals them into the passed in
+	// destination.
+	Value(destination interface{}) error
+
+	// Children GETs the query's results, sorted client-side with Firebase's
+	// ordering rules for the query's OrderBy property. Use it instead of
+	// Value when the order of the results matters, since decoding into a Go
+	// map loses it.
+	Children() ([]KeyValue, error)
+
+	// ValueOrdered GETs the query's results, in the same order as Children,
+	// and unmarshals each child's value into a new element of the slice that
+	// destination points to.
+	ValueOrdered(destination interface{}) error
+
+	// ValueRaw and ValueReader GET the query's results without decoding
+	// them, like Reference's ValueRaw and ValueReader.
+	ValueRaw() (json.RawMessage, error)
+	ValueReader() (io.ReadCloser, error)
+
+	// ValueExport GETs the query's results in Firebase's export format, like
+	// Reference's ValueExport.
+	ValueExport(destination *Node) error
+
+	// ValueStream GETs the query's results and calls callback with each
+	// child as it is decoded, like Reference's ValueStream. Children arrive
+	// in the order Firebase sends them, which is not necessarily the query's
+	// order.
+	ValueStream(callback ChildCallback) error
+
+	// Watch streams changes to the query's results in real-time. It behaves
+	// like Reference's Watch.
+	Watch(unmarshaller EventUnmarshaller, stop <-chan bool) (<-chan StreamEvent, error)
+
+	// Query functions. They map directly to the Firebase operations.
+	// https://www.firebase.com/docs/rest/guide/retrieving-data.html#section-rest-queries
+	//
+	// OrderBy orders by a child property, or by one of the special KeyProp,
+	// ValueProp or PriorityProp properties. OrderByKey, OrderByValue,
+	// OrderByPriority and OrderByChild are shorthands that avoid mistyping
+	// them; OrderByChild also validates the child path.
+	OrderBy(prop string) Query
+	OrderByKey() Query
+	OrderByValue() Query
+	OrderByPriority() Query
+	OrderByChild(path string) Query
+
+	// Range filters. Their values must encode to a JSON null, boolean, number
+	// or string (a string when ordering by key). StartAfter and EndBefore
+	// exclude children equal to the value.
+	EqualTo(value interface{}) Query
+	StartAt(value interface{}) Query
+	StartAfter(value interface{}) Query
+	EndAt(value interface{}) Query
+	EndBefore(value interface{}) Query
+
+	// Range filters with a key tiebreaker: children whose value equals the
+	// given value are also compared by key. The REST API has no way to send
+	// the key, so it is applied client-side to the results of Value,
+	// Children and ValueOrdered, and limits count the children it removes.
+	// Watch does not apply it. They cannot be used when ordering by key.
+	StartAtKey(value interface{}, key string) Query
+	StartAfterKey(value interface{}, key string) Query
+	EndAtKey(value interface{}, key string) Query
+	EndBeforeKey(value interface{}, key string) Query
+
+	LimitToFirst(limit uint) Query
+	LimitToLast(limit uint) Query
+}
+
 // RawEvent contains the raw event and data payloads of Firebase Event Source
 // protocol messages. This is emitted by the Api's Stream method.
 type RawEvent struct {
@@ -135,6 +348,9 @@ type StreamEvent struct {
 // Users of this library can implement their own Api-conformant types for
 // testing purposes. To use your own test Api type, pass it in to the NewClient
 // function.
+//
+// To modify or observe the requests of the default implementation, rather than
+// replacing it, give NewClient interceptors with WithInterceptors.
 type Api interface {
 	// Call is responsible for performing HTTP transactions such as GET, POST,
 	// PUT, PATCH, and DELETE. It is used to communicate with Firebase by all
@@ -146,7 +362,7 @@ type Api interface {
 	//  - `body`: Data to be marshalled to JSON (it's the responsibility of Call to do the marshalling and unmarshalling)
 	//  - `params`: Additional parameters to be passed to firebase
 	//  - `dest`: The object to save the unmarshalled response body to.
-	//    It's up to this method to unmarshal correctly, the default implemenation just uses `json.Unmarshal`
+	//    It's up to this method to unmarshal correctly, the default implemenation uses the client's Codec (`encoding/json` unless WithCodec is given)
 	Call(method, path, auth string, body interface{}, params map[string]string, dest interface{}) error
 
 	// Stream is responsible for implementing a SSE/Event Source client that
@@ -1,6 +1,7 @@
 package firebase
 
 import (
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -20,14 +21,35 @@ var (
 	// By default, never time out reading from a stream
 	streamTimeoutDefault = time.Duration(0)
 
-	// maxTriesDefault is the default number of times a connection to Firebase
-	// will be retried by the httpcontrol library.
+	// maxTriesDefault is the default number of times a stream connection to
+	// Firebase will be retried by the httpcontrol library.
 	maxTriesDefault = 300
 
+	// callMaxTriesDefault is the default number of times a call is tried,
+	// the first time included.
+	callMaxTriesDefault = 11
+
+	// callMaxTries is the number of times Call tries a call before giving
+	// up, the first time included. It is read from FIREBASE_MAXTRIES, like
+	// the tries of stream connections.
+	callMaxTries = callMaxTriesDefault
+
+	// minRetryDelay is how long Call waits before retrying a failed call.
+	// The wait doubles with every retry, up to maxRetryDelay.
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 5 * time.Second
+
 	// maxIdleConnsDefault is the default maximum number of idle connections to
-	// Firebase that the httpcontrol library will allow.
+	// Firebase kept open for reuse.
 	maxIdleConnsDefault = 30
 
+	// idleConnTimeoutDefault is how long an idle connection to Firebase is
+	// kept open for reuse.
+	idleConnTimeoutDefault = time.Duration(90 * time.Second)
+
+	// tlsHandshakeTimeoutDefault bounds the TLS handshake of new connections.
+	tlsHandshakeTimeoutDefault = time.Duration(10 * time.Second)
+
 	// httpClient is the connection pool for regular short lived HTTP calls to
 	// Firebase.
 	httpClient *http.Client
@@ -39,6 +61,7 @@ var (
 
 func newTimeoutClient(connectTimeout, readWriteTimeout time.Duration, maxTries, maxIdleConnsPerHost int) *http.Client {
 	return &http.Client{
+		CheckRedirect: keepAuthorization,
 		Transport: &httpcontrol.Transport{
 			RequestTimeout:      readWriteTimeout,
 			DialTimeout:         connectTimeout,
@@ -49,6 +72,31 @@ func newTimeoutClient(connectTimeout, readWriteTimeout time.Duration, maxTries,
 	}
 }
 
+// newCallClient returns the client of regular calls. Its connections are kept
+// alive and reused from call to call, over HTTP/2 when Firebase supports it,
+// so that most calls skip dialing and the TLS handshake. Failed calls are
+// retried by Call itself, with a backoff, up to callMaxTries times.
+func newCallClient(connectTimeout, readWriteTimeout time.Duration, maxIdleConnsPerHost int) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout:       readWriteTimeout,
+		CheckRedirect: keepAuthorization,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        maxIdleConnsPerHost,
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			IdleConnTimeout:     idleConnTimeoutDefault,
+			TLSHandshakeTimeout: tlsHandshakeTimeoutDefault,
+		},
+	}
+}
+
 func parseTimeout(envVariableName string, defaultTimeout time.Duration) time.Duration {
 	if timeout := os.Getenv(envVariableName); timeout != "" {
 		if timeoutDuration, err := time.ParseDuration(timeout); err == nil {
@@ -100,7 +148,9 @@ func init() {
 	maxTries := parseTunable("FIREBASE_MAXTRIES", maxTriesDefault)
 	maxIdleConnsPerHost := parseTunable("FIREBASE_MAXIDLE", maxIdleConnsDefault)
 
-	httpClient = newTimeoutClient(connectTimeout, readWriteTimeout, maxTries,
+	callMaxTries = parseTunable("FIREBASE_MAXTRIES", callMaxTriesDefault)
+
+	httpClient = newCallClient(connectTimeout, readWriteTimeout,
 		maxIdleConnsPerHost)
 	streamClient = newTimeoutClient(connectTimeout, streamTimeout, maxTries,
 		maxIdleConnsPerHost)
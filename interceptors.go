@@ -0,0 +1,258 @@
+package firebase
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Request is a call or a stream of the default Api implementation, as seen by
+// interceptors. Interceptors may modify it before passing it on.
+type Request struct {
+	// Context is the context of the request. It carries the request's
+	// span, and cancels the request when done.
+	Context context.Context
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// Path is the URL of the location, without the .json suffix.
+	Path string
+
+	// Auth is the auth token sent with the request, if any.
+	Auth string
+
+	// AuthMode is how Auth is sent.
+	AuthMode AuthMode
+
+	// Params are the query string params. They are a copy of the caller's.
+	Params map[string]string
+
+	// Header holds extra headers sent with the request.
+	Header http.Header
+
+	// Body is the request's body, before it is marshalled.
+	Body interface{}
+
+	// Destination is what a call's response is decoded into. It is nil for
+	// streams, and for calls whose response is discarded.
+	Destination interface{}
+
+	// Stream is true for the requests of Stream.
+	Stream bool
+
+	// StatusCode is the HTTP status of Firebase's response, once there is
+	// one.
+	StatusCode int
+
+	// Retries is the number of times the request was attempted again.
+	Retries int
+
+	// ResponseSize is the number of bytes of the response's body read, once
+	// a call is done. It is 0 for streams, whose body is still being read.
+	ResponseSize int64
+
+	// codec is the codec of the Api the request is made by. If nil, the
+	// default codec is used.
+	codec Codec
+}
+
+// newRequest returns a Request with a copy of params.
+func newRequest(ctx context.Context, method, path, auth string, mode AuthMode, body interface{}, params map[string]string) *Request {
+	copied := make(map[string]string, len(params))
+	for key, value := range params {
+		copied[key] = value
+	}
+
+	return &Request{
+		Context:  ctx,
+		Method:   method,
+		Path:     path,
+		Auth:     auth,
+		AuthMode: mode,
+		Params:   copied,
+		Header:   http.Header{},
+		Body:     body,
+	}
+}
+
+// Respond decodes a JSON response into the request's destination, with the
+// same codec as Firebase's responses. It lets a call interceptor answer a
+// request without passing it on.
+func (r *Request) Respond(body []byte) error {
+	if r.Destination == nil {
+		return nil
+	}
+
+	codec := r.codec
+	if codec == nil {
+		codec = defaultCodec
+	}
+
+	return codec.Unmarshal(body, r.Destination)
+}
+
+// CallFunc performs a call. It is the rest of the chain, as seen by a call
+// interceptor.
+type CallFunc func(req *Request) error
+
+// StreamFunc opens a stream. It is the rest of the chain, as seen by a stream
+// interceptor.
+type StreamFunc func(req *Request) (<-chan RawEvent, error)
+
+// Interceptor wraps the calls and streams of the default Api implementation.
+// An interceptor can modify the request before passing it to next, answer it
+// itself without calling next, or observe the result of next.
+type Interceptor interface {
+	InterceptCall(req *Request, next CallFunc) error
+	InterceptStream(req *Request, next StreamFunc) (<-chan RawEvent, error)
+}
+
+// CallInterceptor is an Interceptor of calls only. Streams are passed on.
+type CallInterceptor func(req *Request, next CallFunc) error
+
+func (i CallInterceptor) InterceptCall(req *Request, next CallFunc) error {
+	return i(req, next)
+}
+
+func (i CallInterceptor) InterceptStream(req *Request, next StreamFunc) (<-chan RawEvent, error) {
+	return next(req)
+}
+
+// StreamInterceptor is an Interceptor of streams only. Calls are passed on.
+type StreamInterceptor func(req *Request, next StreamFunc) (<-chan RawEvent, error)
+
+func (i StreamInterceptor) InterceptCall(req *Request, next CallFunc) error {
+	return next(req)
+}
+
+func (i StreamInterceptor) InterceptStream(req *Request, next StreamFunc) (<-chan RawEvent, error) {
+	return i(req, next)
+}
+
+// callChain returns the interceptors of calls, wrapped around call.
+func (f *firebaseAPI) callChain() CallFunc {
+	chain := CallFunc(f.call)
+
+	for i := len(f.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := f.interceptors[i], chain
+		chain = func(req *Request) error {
+			return interceptor.InterceptCall(req, next)
+		}
+	}
+
+	return chain
+}
+
+// streamChain returns the interceptors of streams, wrapped around stream.
+func (f *firebaseAPI) streamChain(stop <-chan bool) StreamFunc {
+	chain := func(req *Request) (<-chan RawEvent, error) {
+		return f.stream(req, stop)
+	}
+
+	for i := len(f.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := f.interceptors[i], StreamFunc(chain)
+		chain = func(req *Request) (<-chan RawEvent, error) {
+			return interceptor.InterceptStream(req, next)
+		}
+	}
+
+	return chain
+}
+
+// callInterceptor adapts a function run around both calls and streams, which
+// only needs to know whether the rest of the chain failed.
+type callInterceptor func(req *Request, next func() error) error
+
+func (i callInterceptor) InterceptCall(req *Request, next CallFunc) error {
+	return i(req, func() error { return next(req) })
+}
+
+func (i callInterceptor) InterceptStream(req *Request, next StreamFunc) (<-chan RawEvent, error) {
+	var events <-chan RawEvent
+
+	err := i(req, func() error {
+		var err error
+		events, err = next(req)
+		return err
+	})
+
+	return events, err
+}
+
+// LoggingInterceptor logs every call and stream, with its duration and
+// result, to logger. The standard logger is used if logger is nil. Auth
+// tokens are not logged.
+func LoggingInterceptor(logger *log.Logger) Interceptor {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return callInterceptor(func(req *Request, next func() error) error {
+		kind := "call"
+		if req.Stream {
+			kind = "stream"
+		}
+
+		start := time.Now()
+		err := next()
+		elapsed := time.Since(start)
+
+		if err != nil {
+			logger.Printf("firebase: %s %s %s failed after %v: %v", kind, req.Method,
+				req.Path, elapsed, err)
+		} else {
+			logger.Printf("firebase: %s %s %s: %d in %v", kind, req.Method, req.Path,
+				req.StatusCode, elapsed)
+		}
+
+		return err
+	})
+}
+
+// CallMetrics describes a finished call, or an opened stream, for
+// MetricsInterceptor.
+type CallMetrics struct {
+	Method     string
+	Path       string
+	Stream     bool
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// MetricsInterceptor calls record once each call is done, and once each
+// stream is opened.
+func MetricsInterceptor(record func(CallMetrics)) Interceptor {
+	return callInterceptor(func(req *Request, next func() error) error {
+		start := time.Now()
+		err := next()
+
+		record(CallMetrics{
+			Method:     req.Method,
+			Path:       req.Path,
+			Stream:     req.Stream,
+			StatusCode: req.StatusCode,
+			Duration:   time.Since(start),
+			Err:        err,
+		})
+
+		return err
+	})
+}
+
+// AuthInterceptor sets the auth token of every call and stream to the one
+// returned by token, which is called for each of them. It lets a client use
+// tokens that change over time.
+func AuthInterceptor(token func() (string, error)) Interceptor {
+	return callInterceptor(func(req *Request, next func() error) error {
+		auth, err := token()
+		if err != nil {
+			return err
+		}
+
+		req.Auth = auth
+		return next()
+	})
+}
@@ -0,0 +1,176 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Interceptors", func() {
+	var (
+		testServer *httptest.Server
+		requests   []*http.Request
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			if r.Header.Get("Accept") == "text/event-stream" {
+				fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":1}\n\n")
+				return
+			}
+			fmt.Fprint(w, `{"A":1}`)
+		}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	newClient := func(interceptors ...Interceptor) Client {
+		return NewClient(testServer.URL, testAuth, nil, WithInterceptors(interceptors...))
+	}
+
+	It("Runs interceptors in order, around the call", func() {
+		var order []string
+		trace := func(name string) Interceptor {
+			return CallInterceptor(func(req *Request, next CallFunc) error {
+				order = append(order, name+" before")
+				err := next(req)
+				order = append(order, name+" after")
+				return err
+			})
+		}
+
+		var w Widget
+		Expect(newClient(trace("outer"), trace("inner")).Value(&w)).To(BeNil())
+		Expect(w.A).To(Equal(1))
+		Expect(order).To(Equal([]string{"outer before", "inner before", "inner after", "outer after"}))
+	})
+
+	It("Lets interceptors modify the request", func() {
+		params := map[string]string{"shallow": "true"}
+
+		modify := CallInterceptor(func(req *Request, next CallFunc) error {
+			req.Header.Set("X-Audit", "yes")
+			req.Params["print"] = "pretty"
+			return next(req)
+		})
+
+		err := newClient(modify).Update("a", Widget{A: 1}, params)
+		Expect(err).To(BeNil())
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Header.Get("X-Audit")).To(Equal("yes"))
+		Expect(requests[0].URL.Query().Get("print")).To(Equal("pretty"))
+		Expect(params).To(Equal(map[string]string{"shallow": "true"}))
+	})
+
+	It("Lets interceptors answer without calling Firebase", func() {
+		cache := CallInterceptor(func(req *Request, next CallFunc) error {
+			return req.Respond([]byte(`{"A":42}`))
+		})
+
+		var w Widget
+		Expect(newClient(cache).Value(&w)).To(BeNil())
+		Expect(w.A).To(Equal(42))
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("Decodes the answers of interceptors with the client's codec", func() {
+		cache := CallInterceptor(func(req *Request, next CallFunc) error {
+			return req.Respond([]byte(`{"id":9007199254740993}`))
+		})
+
+		c := NewClient(testServer.URL, testAuth, nil,
+			WithCodec(JSONCodec{UseNumber: true}), WithInterceptors(cache))
+		var value map[string]interface{}
+		Expect(c.Value(&value)).To(BeNil())
+		Expect(value["id"]).To(Equal(json.Number("9007199254740993")))
+	})
+
+	It("Lets interceptors reject requests", func() {
+		quota := errors.New("quota exceeded")
+		reject := CallInterceptor(func(req *Request, next CallFunc) error {
+			if req.Method != "GET" {
+				return quota
+			}
+			return next(req)
+		})
+
+		err := newClient(reject).Remove("a", nil)
+		Expect(err).To(Equal(quota))
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("Intercepts streams", func() {
+		var streamed []string
+		observe := StreamInterceptor(func(req *Request, next StreamFunc) (<-chan RawEvent, error) {
+			streamed = append(streamed, req.Method+" "+req.Path)
+			return next(req)
+		})
+
+		stop := make(chan bool)
+		defer close(stop)
+
+		events, err := newClient(observe).Watch(nil, stop)
+		Expect(err).To(BeNil())
+		Eventually(events).Should(Receive())
+		Expect(streamed).To(Equal([]string{"GET " + testServer.URL + "/"}))
+	})
+
+	Describe("Built-in interceptors", func() {
+		It("Logs calls without their auth", func() {
+			var buf bytes.Buffer
+			logger := log.New(&buf, "", 0)
+
+			c := NewClient(testServer.URL, "secret-token", nil,
+				WithInterceptors(LoggingInterceptor(logger)))
+
+			var w Widget
+			Expect(c.Child("a").Value(&w)).To(BeNil())
+			Expect(buf.String()).To(HavePrefix("firebase: call GET " + testServer.URL + "/a: 200 in "))
+			Expect(buf.String()).NotTo(ContainSubstring("secret-token"))
+		})
+
+		It("Records metrics", func() {
+			var metrics []CallMetrics
+
+			c := newClient(MetricsInterceptor(func(m CallMetrics) {
+				metrics = append(metrics, m)
+			}))
+			_, err := c.Set("a", 1, nil)
+			Expect(err).To(BeNil())
+
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].Method).To(Equal("PUT"))
+			Expect(metrics[0].StatusCode).To(Equal(200))
+			Expect(metrics[0].Err).To(BeNil())
+			Expect(metrics[0].Duration).To(BeNumerically(">", 0))
+		})
+
+		It("Sets the auth of each request", func() {
+			tokens := []string{"first", "second"}
+			auth := AuthInterceptor(func() (string, error) {
+				token := tokens[0]
+				tokens = tokens[1:]
+				return token, nil
+			})
+
+			c := newClient(auth)
+			var w Widget
+			Expect(c.Value(&w)).To(BeNil())
+			Expect(c.Value(&w)).To(BeNil())
+
+			Expect(requests[0].URL.Query().Get("auth")).To(Equal("first"))
+			Expect(requests[1].URL.Query().Get("auth")).To(Equal("second"))
+		})
+	})
+})
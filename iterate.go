@@ -0,0 +1,242 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// Cursor marks the position of an Iterator: the last child it yielded. It can
+// be saved (it marshals to JSON) and given to Resume to continue iterating
+// later.
+type Cursor struct {
+	// Key is the key of the last child yielded.
+	Key string `json:"key"`
+
+	// Value is the JSON encoding of the value the last child was ordered by.
+	// It is empty when iterating in key order.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Iterator pages through the children of a location, so that locations with
+// too many children to read with a single Value call can be read in pieces.
+// It is created by a Reference's Iterate method.
+//
+// Each page is read with a query limited to the page size, starting at the
+// last child of the previous page. That child is returned again by Firebase,
+// so it is skipped. Children are visited in key order, unless OrderByChild or
+// OrderByValue is used, in which case ties are broken by key.
+//
+// Children are read by ranging over All, which needs Go 1.23 or later.
+//
+// An Iterator is not safe for concurrent use.
+type Iterator struct {
+	ref      *client
+	pageSize uint
+	order    string
+	cursor   *Cursor
+	err      error
+}
+
+func (c *client) Iterate(pageSize uint) *Iterator {
+	it := &Iterator{ref: c, pageSize: pageSize, order: KeyProp}
+	if pageSize == 0 {
+		it.err = errors.New("firebase: iterator page size must be positive")
+	}
+
+	return it
+}
+
+// copy returns a copy of the iterator, so that the option methods don't
+// modify an iterator that may already be in use.
+func (it *Iterator) copy() *Iterator {
+	newIt := *it
+	return &newIt
+}
+
+// OrderByChild returns a copy of the iterator that visits children ordered by
+// the child property at path.
+func (it *Iterator) OrderByChild(path string) *Iterator {
+	newIt := it.copy()
+
+	childPath, err := ParsePath(path)
+	switch {
+	case err != nil:
+		newIt.err = err
+	case len(childPath) == 0:
+		newIt.err = &InvalidPathError{Path: path, Reason: "empty child path"}
+	default:
+		newIt.order = childPath.String()
+	}
+
+	return newIt
+}
+
+// OrderByValue returns a copy of the iterator that visits children ordered by
+// their values.
+func (it *Iterator) OrderByValue() *Iterator {
+	newIt := it.copy()
+	newIt.order = ValueProp
+	return newIt
+}
+
+// Resume returns a copy of the iterator that starts right after the given
+// cursor, as returned by Cursor. The iterator must use the same ordering as
+// the one the cursor was saved from.
+func (it *Iterator) Resume(cursor Cursor) *Iterator {
+	newIt := it.copy()
+	newIt.cursor = &cursor
+	return newIt
+}
+
+// Cursor returns the position of the last child yielded by the iterator, or
+// nil if it hasn't yielded any.
+func (it *Iterator) Cursor() *Cursor {
+	if it.cursor == nil {
+		return nil
+	}
+
+	cursor := *it.cursor
+	return &cursor
+}
+
+// Err returns the error that stopped the iteration, if any. It should be
+// checked once the loop over All is done.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// page reads up to limit children, starting at the cursor. It returns the
+// children that come after the cursor, and whether Firebase returned as many
+// children as were asked for.
+func (it *Iterator) page(limit uint) ([]KeyValue, bool, error) {
+	if it.cursor != nil && it.order != KeyProp {
+		isObject, err := orderedByObject(it.cursor.Value)
+		if err != nil {
+			return nil, false, err
+		}
+		if isObject {
+			return it.objectPage(limit)
+		}
+	}
+
+	q := it.ref.query().OrderBy(it.order).(*query)
+
+	if it.cursor != nil {
+		if it.order == KeyProp {
+			q = q.StartAt(it.cursor.Key).(*query)
+		} else {
+			q = q.StartAfterKey(it.cursor.Value, it.cursor.Key).(*query)
+		}
+	}
+
+	q = q.LimitToFirst(limit).(*query)
+	if q.err != nil {
+		return nil, false, q.err
+	}
+
+	children, err := q.ref.orderedChildren(q.order, q.params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	full := uint(len(children)) == limit
+
+	if it.cursor == nil {
+		return children, full, nil
+	}
+
+	if it.order == KeyProp {
+		if len(children) > 0 && children[0].Key == it.cursor.Key {
+			children = children[1:]
+		}
+		return children, full, nil
+	}
+
+	values, err := childSortValues(q.order, children)
+	if err != nil {
+		return nil, false, err
+	}
+
+	after := children[:0]
+	for _, child := range children {
+		if q.inRange(child.Key, values[child.Key]) {
+			after = append(after, child)
+		}
+	}
+
+	return after, full, nil
+}
+
+// objectPage is page for a cursor whose child is ordered by an object (or an
+// array). Objects can't be given to startAt, but they all tie, and come after
+// every other value, so the children after the cursor are the ones ordered by
+// an object with a greater key. They are read in key order.
+func (it *Iterator) objectPage(limit uint) ([]KeyValue, bool, error) {
+	q := it.ref.query().OrderBy(KeyProp).StartAt(it.cursor.Key).LimitToFirst(limit).(*query)
+	if q.err != nil {
+		return nil, false, q.err
+	}
+
+	children, err := q.ref.orderedChildren(q.order, q.params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	full := uint(len(children)) == limit
+
+	values, err := childSortValues(it.order, children)
+	if err != nil {
+		return nil, false, err
+	}
+
+	after := children[:0]
+	for _, child := range children {
+		if child.Key != it.cursor.Key && values[child.Key].rank == rankObject {
+			after = append(after, child)
+		}
+	}
+
+	return after, full, nil
+}
+
+// orderedByObject returns whether the value of a cursor is an object or an
+// array.
+func orderedByObject(value json.RawMessage) (bool, error) {
+	if len(value) == 0 {
+		return false, nil
+	}
+
+	var parsed interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(&parsed); err != nil {
+		return false, err
+	}
+
+	return newSortValue(parsed).rank == rankObject, nil
+}
+
+// advance moves the cursor to child.
+func (it *Iterator) advance(child KeyValue) error {
+	cursor := &Cursor{Key: child.Key}
+
+	if it.order != KeyProp {
+		var parsed interface{}
+
+		decoder := json.NewDecoder(bytes.NewReader(child.Value))
+		decoder.UseNumber()
+		if err := decoder.Decode(&parsed); err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(orderedValue(it.order, parsed))
+		if err != nil {
+			return err
+		}
+		cursor.Value = value
+	}
+
+	it.cursor = cursor
+	return nil
+}
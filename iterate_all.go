@@ -0,0 +1,56 @@
+//go:build go1.23
+
+package firebase
+
+import (
+	"encoding/json"
+	"iter"
+)
+
+// All returns a sequence of the key and raw JSON value of each child. Stopping
+// the loop early leaves the iterator's cursor on the last child yielded, so
+// calling All again continues from there.
+func (it *Iterator) All() iter.Seq2[string, json.RawMessage] {
+	return func(yield func(string, json.RawMessage) bool) {
+		if it.err != nil {
+			return
+		}
+
+		limit := it.pageSize
+		if it.cursor != nil {
+			limit++
+		}
+
+		for {
+			children, full, err := it.page(limit)
+			if err != nil {
+				it.err = err
+				return
+			}
+
+			for _, child := range children {
+				if it.err = it.advance(child); it.err != nil {
+					return
+				}
+
+				if !yield(child.Key, child.Value) {
+					return
+				}
+			}
+
+			if !full {
+				return
+			}
+
+			// A full page with nothing new in it means that more children
+			// than fit in a page are tied with the cursor's value. Read
+			// bigger pages until we get past them.
+			if len(children) == 0 {
+				limit *= 2
+				continue
+			}
+
+			limit = it.pageSize + 1
+		}
+	}
+}
@@ -0,0 +1,165 @@
+//go:build go1.23
+
+package firebase
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// pagingHandler serves the children of data the way Firebase serves a query
+// ordered by key or value, with startAt and limitToFirst.
+func pagingHandler(data string, pages *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*pages++
+
+		var order string
+		Expect(json.Unmarshal([]byte(r.URL.Query().Get("orderBy")), &order)).To(Succeed())
+		limit, err := strconv.Atoi(r.URL.Query().Get("limitToFirst"))
+		Expect(err).To(BeNil())
+
+		children := childrenOf(data)
+		Expect(sortChildren(order, children)).To(Succeed())
+		values, err := childSortValues(order, children)
+		Expect(err).To(BeNil())
+
+		page := map[string]json.RawMessage{}
+		startAt := r.URL.Query().Get("startAt")
+		for _, child := range children {
+			if startAt != "" {
+				var start interface{}
+				decoder := json.NewDecoder(strings.NewReader(startAt))
+				decoder.UseNumber()
+				Expect(decoder.Decode(&start)).To(Succeed())
+
+				if order == KeyProp && compareKeys(child.Key, start.(string)) < 0 {
+					continue
+				}
+				if order != KeyProp && compareValues(values[child.Key], newSortValue(start)) < 0 {
+					continue
+				}
+			}
+
+			if len(page) == limit {
+				break
+			}
+			page[child.Key] = child.Value
+		}
+
+		Expect(json.NewEncoder(w).Encode(page)).To(Succeed())
+	}
+}
+
+func collect(it *Iterator) []string {
+	keys := []string{}
+	for key := range it.All() {
+		keys = append(keys, key)
+	}
+	Expect(it.Err()).To(BeNil())
+	return keys
+}
+
+var _ = Describe("Iterating over large locations", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		data       string
+		pages      int
+	)
+
+	JustBeforeEach(func() {
+		pages = 0
+		testServer, testClient = fakeServer(pagingHandler(data, &pages))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	Context("In key order", func() {
+		BeforeEach(func() {
+			data = `{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6, "g": 7}`
+		})
+
+		It("Reads every child once, a page at a time", func() {
+			Expect(collect(testClient.Iterate(3))).To(Equal(
+				[]string{"a", "b", "c", "d", "e", "f", "g"}))
+			Expect(pages).To(Equal(3))
+		})
+
+		It("Resumes from a saved cursor", func() {
+			it := testClient.Iterate(2)
+			for key := range it.All() {
+				if key == "c" {
+					break
+				}
+			}
+
+			saved, err := json.Marshal(it.Cursor())
+			Expect(err).To(BeNil())
+
+			var cursor Cursor
+			Expect(json.Unmarshal(saved, &cursor)).To(Succeed())
+			Expect(collect(testClient.Iterate(2).Resume(cursor))).To(Equal(
+				[]string{"d", "e", "f", "g"}))
+		})
+	})
+
+	Context("In value order, with ties", func() {
+		BeforeEach(func() {
+			data = `{"a": 3, "b": 1, "c": 1, "d": 1, "e": 1, "f": 2, "g": 0}`
+		})
+
+		It("Breaks ties by key, even when they span several pages", func() {
+			Expect(collect(testClient.Iterate(2).OrderByValue())).To(Equal(
+				[]string{"g", "b", "c", "d", "e", "f", "a"}))
+		})
+	})
+
+	Context("By child, with a cursor", func() {
+		BeforeEach(func() {
+			data = `{"a": {"n": 2}, "b": {"n": 1}, "c": {"n": 2}, "d": {"n": 3}}`
+		})
+
+		It("Starts right after the cursor", func() {
+			cursor := Cursor{Key: "a", Value: json.RawMessage("2")}
+			it := testClient.Iterate(10).OrderByChild("n").Resume(cursor)
+			Expect(collect(it)).To(Equal([]string{"c", "d"}))
+		})
+	})
+
+	Context("In value order, with objects", func() {
+		BeforeEach(func() {
+			data = `{"a": {"x": 1}, "b": {"x": 2}, "c": 3, "d": {"x": 0}}`
+		})
+
+		It("Pages past objects in key order", func() {
+			Expect(collect(testClient.Iterate(2).OrderByValue())).To(Equal(
+				[]string{"c", "a", "b", "d"}))
+		})
+	})
+
+	Context("In value order, with only objects", func() {
+		BeforeEach(func() {
+			data = `{"a": {"x": 1}, "b": {"x": 2}, "c": {"x": 0}}`
+		})
+
+		It("Reads past a full page of objects", func() {
+			Expect(collect(testClient.Iterate(2).OrderByValue())).To(Equal([]string{"a", "b", "c"}))
+		})
+	})
+
+	It("Rejects an empty page size", func() {
+		it := NewClient("https://who.cares.com", "", nil).Iterate(0)
+		for range it.All() {
+			Fail("should not yield")
+		}
+		Expect(it.Err()).To(HaveOccurred())
+	})
+})
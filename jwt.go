@@ -0,0 +1,104 @@
+package firebase
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+)
+
+// errNoPEM is returned for private keys that aren't PEM encoded.
+var errNoPEM = errors.New("firebase: private key is not PEM encoded")
+
+// errNotRSA is returned for private keys that aren't RSA keys.
+var errNotRSA = errors.New("firebase: private key is not an RSA key")
+
+// jwtHeader is the header of a JSON Web Token.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key, in PKCS #8 form (as
+// in service account keys) or PKCS #1 form.
+func parsePrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errNoPEM
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errNotRSA
+	}
+
+	return key, nil
+}
+
+// encodeJWTPart encodes the header or the claims of a JWT.
+func encodeJWTPart(part interface{}) (string, error) {
+	data, err := json.Marshal(part)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// signRS256 returns a JWT of claims, signed by key with RS256. keyID names
+// the key in the token's header, if set.
+func signRS256(claims interface{}, key *rsa.PrivateKey, keyID string) (string, error) {
+	header, err := encodeJWTPart(jwtHeader{Algorithm: "RS256", Type: "JWT", KeyID: keyID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := encodeJWTPart(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := header + "." + payload
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// signHS256 returns a JWT of claims, signed with secret with HS256.
+func signHS256(claims interface{}, secret string) (string, error) {
+	header, err := encodeJWTPart(jwtHeader{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := encodeJWTPart(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := header + "." + payload
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,80 @@
+package firebase
+
+import (
+	"io"
+	"time"
+)
+
+// MetricsCollector receives measurements of the library's traffic with
+// Firebase. Implementations must be safe for concurrent use, and quick: they
+// are called inline, some of them for every read of a response body.
+//
+// PrometheusCollector is an implementation that serves the measurements in
+// the Prometheus text format.
+type MetricsCollector interface {
+	// CallDone is called when a call is done, retries included. status is
+	// the HTTP status of the last attempt, or 0 if it got no response.
+	CallDone(method string, status int, duration time.Duration)
+
+	// CallRetried is called each time a call is attempted again.
+	CallRetried(method string)
+
+	// BytesSent and BytesReceived count the bytes of request and response
+	// bodies, of calls and streams.
+	BytesSent(n int64)
+	BytesReceived(n int64)
+
+	// StreamOpened and StreamClosed are called when a stream's connection
+	// is opened, and when it ends.
+	StreamOpened()
+	StreamClosed()
+
+	// EventReceived is called for each event read from a stream.
+	EventReceived(eventType string)
+
+	// StreamReconnected is called when a watch reopens its stream.
+	StreamReconnected()
+
+	// EventDropped is called for each event read from a stream that Watch
+	// does not deliver, such as events of unknown types.
+	EventDropped(eventType string)
+}
+
+// WithMetrics makes the client report its calls, streams and watches to
+// collector. Calls and streams are only measured by the default Api
+// implementation.
+func WithMetrics(collector MetricsCollector) ClientOption {
+	return func(c *client) {
+		if collector == nil {
+			collector = nopCollector{}
+		}
+		c.metrics = collector
+	}
+}
+
+// nopCollector is the MetricsCollector of clients created without one.
+type nopCollector struct{}
+
+func (nopCollector) CallDone(string, int, time.Duration) {}
+func (nopCollector) CallRetried(string)                  {}
+func (nopCollector) BytesSent(int64)                     {}
+func (nopCollector) BytesReceived(int64)                 {}
+func (nopCollector) StreamOpened()                       {}
+func (nopCollector) StreamClosed()                       {}
+func (nopCollector) EventReceived(string)                {}
+func (nopCollector) StreamReconnected()                  {}
+func (nopCollector) EventDropped(string)                 {}
+
+// countingReader reports the bytes read through it to count.
+type countingReader struct {
+	io.ReadCloser
+	count func(n int64)
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	if n > 0 {
+		r.count(int64(n))
+	}
+	return n, err
+}
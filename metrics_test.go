@@ -0,0 +1,110 @@
+package firebase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		testServer *httptest.Server
+		metrics    *PrometheusCollector
+		testClient Client
+		failures   int
+	)
+
+	BeforeEach(func() {
+		failures = 0
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+
+			if r.Header.Get("Accept") == "text/event-stream" {
+				fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":{\"a\":1}}\n\n")
+				fmt.Fprint(w, "event: keep-alive\ndata: null\n\n")
+				fmt.Fprint(w, "event: mystery\ndata: null\n\n")
+				return
+			}
+
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			fmt.Fprint(w, `{"A":1}`)
+		}))
+
+		metrics = NewPrometheusCollector()
+		testClient = NewClient(testServer.URL, testAuth, nil, WithMetrics(metrics))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	exposition := func() string {
+		recorder := httptest.NewRecorder()
+		metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+		return recorder.Body.String()
+	}
+
+	It("Counts calls by method and status, with their retries and bytes", func() {
+		failures = 2
+
+		var w Widget
+		Expect(testClient.Value(&w)).To(BeNil())
+		_, err := testClient.Set("a", 12345, nil)
+		Expect(err).To(BeNil())
+
+		text := exposition()
+		Expect(text).To(ContainSubstring(`firebase_requests_total{method="GET",status="200"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`firebase_requests_total{method="PUT",status="200"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`firebase_request_retries_total{method="GET"} 2` + "\n"))
+		Expect(text).To(ContainSubstring(`firebase_request_duration_seconds_bucket{method="GET",status="200",le="+Inf"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`firebase_request_duration_seconds_count{method="PUT",status="200"} 1` + "\n"))
+		// Three attempts of a GET with a null body, and a PUT of 12345.
+		Expect(text).To(ContainSubstring("firebase_sent_bytes_total 17\n"))
+		Expect(text).To(ContainSubstring("firebase_received_bytes_total 14\n"))
+		Expect(text).To(ContainSubstring("# TYPE firebase_request_duration_seconds histogram\n"))
+	})
+
+	It("Counts streams, their events, and the events Watch drops", func() {
+		stop := make(chan bool)
+		events, err := testClient.Watch(nil, stop)
+		Expect(err).To(BeNil())
+
+		var received []StreamEvent
+		for event := range events {
+			received = append(received, event)
+		}
+		close(stop)
+		Expect(received).To(HaveLen(1))
+
+		text := exposition()
+		Expect(text).To(ContainSubstring(`firebase_stream_events_total{type="put"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`firebase_stream_events_total{type="keep-alive"} 1` + "\n"))
+		Expect(text).To(ContainSubstring(`firebase_dropped_events_total{type="mystery"} 1` + "\n"))
+		Expect(text).To(ContainSubstring("firebase_active_streams 0\n"))
+	})
+
+	It("Reports calls that got no response", func() {
+		testServer.Close()
+
+		var w Widget
+		Expect(testClient.Value(&w)).NotTo(BeNil())
+		Expect(exposition()).To(ContainSubstring(`firebase_requests_total{method="GET",status="error"} 1` + "\n"))
+	})
+
+	It("Escapes label values", func() {
+		metrics.EventDropped("a\"b\\c\nd")
+		Expect(strings.Contains(exposition(),
+			`firebase_dropped_events_total{type="a\"b\\c\nd"} 1`)).To(BeTrue())
+	})
+})
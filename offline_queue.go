@@ -0,0 +1,597 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultReplayInterval is how often an OfflineQueue tries to replay its
+// mutations, unless configured with ReplayInterval.
+const defaultReplayInterval = 5 * time.Second
+
+// ErrConflictSkipped is reported to the OnReplay hook for a mutation that the
+// queue's ConflictPolicy chose not to apply.
+var ErrConflictSkipped = errors.New("firebase: queued mutation skipped by conflict policy")
+
+// ErrQueueClosed is returned by writes made after an OfflineQueue is closed.
+var ErrQueueClosed = errors.New("firebase: offline queue is closed")
+
+// MutationOp is the kind of write of a queued Mutation.
+type MutationOp string
+
+const (
+	MutationSet    MutationOp = "set"
+	MutationUpdate MutationOp = "update"
+	MutationRemove MutationOp = "remove"
+)
+
+// Mutation is a write recorded by an OfflineQueue, to be replayed once
+// Firebase is reachable.
+type Mutation struct {
+	// ID orders the mutations of a queue.
+	ID uint64 `json:"id"`
+
+	Op MutationOp `json:"op"`
+
+	// Path is the location written, relative to the queue's reference.
+	Path string `json:"path"`
+
+	// Value is the JSON written by a set or an update.
+	Value json.RawMessage `json:"value,omitempty"`
+
+	// ETag is the ETag the location had after the queue last wrote it,
+	// including by replaying the mutations queued before this one. It is
+	// empty if the queue never wrote it, or wrote a location above or below
+	// it since.
+	ETag string `json:"etag,omitempty"`
+
+	// Time is when the mutation was queued.
+	Time time.Time `json:"time"`
+}
+
+// ConflictPolicy decides whether a queued mutation is still applied when it
+// is replayed, given the ETag the location has at that time. It is only asked
+// when that ETag isn't the mutation's, i.e. when the location changed since
+// the queue last wrote it, or when the queue never wrote it.
+type ConflictPolicy func(mutation Mutation, currentETag string) bool
+
+// Overwrite is the default ConflictPolicy: every mutation is applied, even if
+// the location changed while the queue was offline.
+func Overwrite(mutation Mutation, currentETag string) bool {
+	return true
+}
+
+// SkipIfChanged is a ConflictPolicy that skips mutations of locations that
+// changed since the queue last wrote them. Mutations of locations the queue
+// never wrote are applied.
+func SkipIfChanged(mutation Mutation, currentETag string) bool {
+	return mutation.ETag == "" || mutation.ETag == currentETag
+}
+
+// QueueOption configures an OfflineQueue.
+type QueueOption func(*OfflineQueue)
+
+// WithConflictPolicy sets the policy used to replay mutations. With any
+// policy other than the default, replayed writes are conditional (see
+// IfMatch): a write is only made if its location didn't change since the
+// policy was asked, and the policy is asked again if it did.
+func WithConflictPolicy(policy ConflictPolicy) QueueOption {
+	return func(q *OfflineQueue) {
+		q.policy = policy
+	}
+}
+
+// ReplayInterval sets how often the queue tries to replay its mutations
+// while they can't be written.
+func ReplayInterval(interval time.Duration) QueueOption {
+	return func(q *OfflineQueue) {
+		q.interval = interval
+	}
+}
+
+// OnReplay sets a hook called after each queued mutation is replayed. err is
+// nil if the mutation was written, ErrConflictSkipped if the conflict policy
+// skipped it, or the error Firebase rejected it with. In every case the
+// mutation is then removed from the queue.
+func OnReplay(hook func(mutation Mutation, err error)) QueueOption {
+	return func(q *OfflineQueue) {
+		q.onReplay = hook
+	}
+}
+
+// QueueStatus describes the state of an OfflineQueue.
+type QueueStatus struct {
+	// Depth is the number of mutations waiting to be replayed.
+	Depth int
+
+	// Oldest is when the oldest waiting mutation was queued, or the zero
+	// time if none are.
+	Oldest time.Time
+
+	// LastAttempt is when a replay was last attempted.
+	LastAttempt time.Time
+
+	// LastError is the network error that stopped the last replay, if any.
+	LastError error
+}
+
+// OfflineQueue makes writes that survive Firebase being unreachable. A write
+// that fails with a network error is recorded in an append-only journal file,
+// and replayed in order once Firebase can be reached again, even by a later
+// process opening the same journal. Writes made while mutations are waiting
+// are queued behind them, so that writes are always applied in order.
+//
+// Errors other than network errors, such as permission errors, are returned
+// as they are by writes, and reported to the OnReplay hook by replays.
+//
+// An OfflineQueue is safe for concurrent use.
+type OfflineQueue struct {
+	ref      Reference
+	policy   ConflictPolicy
+	interval time.Duration
+	onReplay func(Mutation, error)
+
+	// writeMu is held while a mutation is written, so that they are
+	// written one at a time, in order.
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	journal   *os.File
+	mutations []Mutation
+	nextID    uint64
+	etags     map[string]string
+	status    QueueStatus
+	closed    bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// journalRecord is a line of the journal: either a queued mutation, or the ID
+// of a mutation that was replayed. Wrote and ETag tell whether a replayed
+// mutation was written, and the ETag it left the location with.
+type journalRecord struct {
+	Mutation *Mutation `json:"mutation,omitempty"`
+	Done     uint64    `json:"done,omitempty"`
+	Wrote    bool      `json:"wrote,omitempty"`
+	ETag     string    `json:"etag,omitempty"`
+}
+
+// NewOfflineQueue returns a queue of writes under ref, journaled to the file
+// at journalPath. Mutations left in the journal by an earlier queue are
+// replayed in the background, like new ones.
+func NewOfflineQueue(ref Reference, journalPath string, options ...QueueOption) (*OfflineQueue, error) {
+	q := &OfflineQueue{
+		ref:      ref,
+		interval: defaultReplayInterval,
+		etags:    map[string]string{},
+		nextID:   1,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(q)
+	}
+
+	if err := q.load(journalPath); err != nil {
+		return nil, err
+	}
+
+	go q.replayLoop(len(q.mutations) > 0)
+
+	return q, nil
+}
+
+// load reads the pending mutations of the journal, and rewrites it with only
+// those.
+func (q *OfflineQueue) load(journalPath string) error {
+	contents, err := os.ReadFile(journalPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, line := range bytes.Split(contents, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			// The last line is cut short if the process died while
+			// appending it. That mutation was never acknowledged.
+			break
+		}
+
+		switch {
+		case record.Mutation != nil:
+			q.mutations = append(q.mutations, *record.Mutation)
+			if record.Mutation.ID >= q.nextID {
+				q.nextID = record.Mutation.ID + 1
+			}
+		case record.Done != 0:
+			q.forget(record)
+		}
+	}
+
+	// Compact the journal: write the pending mutations to a new file, and
+	// move it over the old one.
+	tmp, err := os.CreateTemp(filepath.Dir(journalPath), filepath.Base(journalPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	for i := range q.mutations {
+		if err := writeRecord(tmp, journalRecord{Mutation: &q.mutations[i]}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), journalPath); err != nil {
+		return err
+	}
+
+	q.journal, err = os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// forget removes the mutation a done record is about from the queue, and
+// replays its effect on the ETags of the others.
+func (q *OfflineQueue) forget(record journalRecord) {
+	for i, mutation := range q.mutations {
+		if mutation.ID != record.Done {
+			continue
+		}
+
+		q.mutations = append(q.mutations[:i], q.mutations[i+1:]...)
+		if record.Wrote {
+			q.wrote(mutation.Path, record.ETag)
+		}
+		return
+	}
+}
+
+// wrote records the ETag a write left its location with, for the mutations
+// queued behind it to be compared with. The ETags of the locations above and
+// below it are no longer known. q.mu must be held.
+func (q *OfflineQueue) wrote(path, etag string) {
+	for other := range q.etags {
+		if other != path && pathsOverlap(other, path) {
+			delete(q.etags, other)
+		}
+	}
+
+	if etag == "" {
+		delete(q.etags, path)
+	} else {
+		q.etags[path] = etag
+	}
+
+	for i := range q.mutations {
+		switch mutation := &q.mutations[i]; {
+		case mutation.Path == path:
+			mutation.ETag = etag
+		case pathsOverlap(mutation.Path, path):
+			mutation.ETag = ""
+		}
+	}
+}
+
+// pathsOverlap returns whether one of two relative paths is the other, or a
+// location below it. Paths that don't parse are compared as they are.
+func pathsOverlap(a, b string) bool {
+	pathA, errA := ParsePath(a)
+	pathB, errB := ParsePath(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+
+	return isPathPrefix(pathA, pathB) || isPathPrefix(pathB, pathA)
+}
+
+// writeRecord appends a record to the journal, as a line of JSON.
+func writeRecord(journal *os.File, record journalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = journal.Write(append(line, '\n'))
+	return err
+}
+
+// Set writes value at path, relative to the queue's reference. A nil error
+// means the value was either written, or durably queued.
+func (q *OfflineQueue) Set(path string, value interface{}) error {
+	return q.write(MutationSet, path, value)
+}
+
+// Update partially updates the value at path, relative to the queue's
+// reference. A nil error means the update was either written, or durably
+// queued.
+func (q *OfflineQueue) Update(path string, value interface{}) error {
+	return q.write(MutationUpdate, path, value)
+}
+
+// Remove deletes the value at path, relative to the queue's reference. A nil
+// error means the delete was either written, or durably queued.
+func (q *OfflineQueue) Remove(path string) error {
+	return q.write(MutationRemove, path, nil)
+}
+
+func (q *OfflineQueue) write(op MutationOp, path string, value interface{}) error {
+	mutation := Mutation{Op: op, Path: path, Time: time.Now()}
+
+	if op != MutationRemove {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		mutation.Value = encoded
+	}
+
+	q.writeMu.Lock()
+	defer q.writeMu.Unlock()
+
+	q.mu.Lock()
+	closed, waiting := q.closed, len(q.mutations) > 0
+	q.mu.Unlock()
+
+	if closed {
+		return ErrQueueClosed
+	}
+
+	if !waiting {
+		etag, err := q.apply(mutation, "")
+		if err == nil && q.policy != nil {
+			q.mu.Lock()
+			q.wrote(mutation.Path, etag)
+			q.mu.Unlock()
+		}
+		if !isNetworkError(err) {
+			return err
+		}
+	}
+
+	return q.enqueue(mutation)
+}
+
+// enqueue journals a mutation, and adds it to the queue.
+func (q *OfflineQueue) enqueue(mutation Mutation) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	mutation.ID = q.nextID
+	mutation.ETag = q.etags[mutation.Path]
+
+	if err := writeRecord(q.journal, journalRecord{Mutation: &mutation}); err != nil {
+		return err
+	}
+
+	if err := q.journal.Sync(); err != nil {
+		return err
+	}
+
+	q.nextID++
+	q.mutations = append(q.mutations, mutation)
+	return nil
+}
+
+// apply writes a mutation to Firebase, if its location's ETag is still
+// ifMatch, unless that is empty. It returns the ETag the location is left
+// with, if the queue has a conflict policy.
+func (q *OfflineQueue) apply(mutation Mutation, ifMatch string) (string, error) {
+	var etag string
+	options := []WriteOption{IfMatch(ifMatch)}
+
+	// ETags are only needed to detect conflicts.
+	if q.policy != nil {
+		options = append(options, ETag(&etag))
+	}
+
+	ref := q.ref.Child(mutation.Path)
+
+	var err error
+	switch mutation.Op {
+	case MutationSet:
+		_, err = ref.Set("", mutation.Value, nil, options...)
+	case MutationUpdate:
+		err = ref.Update("", mutation.Value, nil, options...)
+	case MutationRemove:
+		err = ref.Remove("", nil, options...)
+	default:
+		err = errors.New("firebase: unknown mutation op " + string(mutation.Op))
+	}
+
+	return etag, err
+}
+
+// Replay writes the queued mutations, in order, until the queue is empty or
+// Firebase can't be reached. It returns the network error that stopped it, if
+// any. Replays also happen in the background, every ReplayInterval.
+func (q *OfflineQueue) Replay() error {
+	for {
+		replayed, err := q.replayNext()
+		if err != nil || !replayed {
+			return err
+		}
+	}
+}
+
+// replayNext replays the oldest queued mutation, if there is one.
+func (q *OfflineQueue) replayNext() (bool, error) {
+	q.writeMu.Lock()
+	defer q.writeMu.Unlock()
+
+	q.mu.Lock()
+	if len(q.mutations) == 0 || q.closed {
+		q.mu.Unlock()
+		return false, nil
+	}
+	mutation := q.mutations[0]
+	q.status.LastAttempt = time.Now()
+	q.mu.Unlock()
+
+	etag, err := q.replay(mutation)
+	if isNetworkError(err) {
+		q.mu.Lock()
+		q.status.LastError = err
+		q.mu.Unlock()
+		return false, err
+	}
+
+	if q.onReplay != nil {
+		q.onReplay(mutation, err)
+	}
+
+	record := journalRecord{Done: mutation.ID}
+	if err == nil && q.policy != nil {
+		record.Wrote, record.ETag = true, etag
+	}
+
+	return true, q.markDone(record)
+}
+
+// replay writes a mutation, as the conflict policy decides. The write is
+// conditional on the ETag the policy was asked about, or on the mutation's if
+// the location didn't change, so that a concurrent change asks the policy
+// again. It returns the ETag the location is left with.
+func (q *OfflineQueue) replay(mutation Mutation) (string, error) {
+	if q.policy == nil {
+		return q.apply(mutation, "")
+	}
+
+	expected := mutation.ETag
+	if expected == "" {
+		current, err := q.ref.Child(mutation.Path).ETag()
+		if err != nil {
+			return "", err
+		}
+
+		if !q.policy(mutation, current) {
+			return "", ErrConflictSkipped
+		}
+		expected = current
+	}
+
+	for {
+		etag, err := q.apply(mutation, expected)
+
+		var mismatch *ETagMismatchError
+		if !errors.As(err, &mismatch) || mismatch.ETag == expected {
+			return etag, err
+		}
+
+		if !q.policy(mutation, mismatch.ETag) {
+			return "", ErrConflictSkipped
+		}
+		expected = mismatch.ETag
+	}
+}
+
+// markDone journals that the oldest mutation was replayed, and removes it
+// from the queue. The journal is emptied once the queue is.
+func (q *OfflineQueue) markDone(record journalRecord) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	mutation := q.mutations[0]
+	q.mutations = q.mutations[1:]
+	q.status.LastError = nil
+	if record.Wrote {
+		q.wrote(mutation.Path, record.ETag)
+	}
+
+	if len(q.mutations) == 0 {
+		if err := q.journal.Truncate(0); err != nil {
+			return err
+		}
+		return q.journal.Sync()
+	}
+
+	if err := writeRecord(q.journal, record); err != nil {
+		return err
+	}
+
+	return q.journal.Sync()
+}
+
+// replayLoop replays the queue every interval, until the queue is closed.
+// Mutations loaded from the journal are replayed right away.
+func (q *OfflineQueue) replayLoop(loaded bool) {
+	defer close(q.done)
+
+	if loaded {
+		q.Replay()
+	}
+
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			q.Replay()
+		}
+	}
+}
+
+// Status returns the current state of the queue.
+func (q *OfflineQueue) Status() QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := q.status
+	status.Depth = len(q.mutations)
+	if len(q.mutations) > 0 {
+		status.Oldest = q.mutations[0].Time
+	}
+
+	return status
+}
+
+// Close stops replaying mutations and closes the journal. Mutations still
+// queued stay in the journal, to be replayed by the next queue opening it.
+func (q *OfflineQueue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.stop)
+	<-q.done
+
+	q.writeMu.Lock()
+	defer q.writeMu.Unlock()
+
+	return q.journal.Close()
+}
+
+// isNetworkError returns whether err means Firebase couldn't be reached, as
+// opposed to Firebase rejecting a request.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,302 @@
+package firebase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OfflineQueue", func() {
+	var (
+		testServer  *httptest.Server
+		testClient  *client
+		journalPath string
+		mu          sync.Mutex
+		offline     bool
+		writes      []string
+		etag        string
+	)
+
+	setOffline := func(value bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		offline = value
+	}
+
+	written := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), writes...)
+	}
+
+	BeforeEach(func() {
+		offline, writes, etag = false, nil, "etag-1"
+
+		dir, err := os.MkdirTemp("", "firebase-queue")
+		Expect(err).To(BeNil())
+		journalPath = filepath.Join(dir, "journal")
+
+		testServer, testClient = fakeServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if offline {
+				// Drop the connection, like an unreachable server.
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			if match := r.Header.Get("if-match"); match != "" && match != etag {
+				w.Header().Set("ETag", etag)
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+
+			if r.Method != "GET" {
+				writes = append(writes, r.Method+" "+r.URL.Path+" "+string(body))
+				etag = fmt.Sprintf("etag-%d", len(writes)+1)
+			}
+			w.Header().Set("ETag", etag)
+			w.Write(body)
+		}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+		os.RemoveAll(filepath.Dir(journalPath))
+	})
+
+	It("Writes straight through while online", func() {
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath)
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		Expect(q.Set("a", 1)).To(BeNil())
+		Expect(q.Update("b", map[string]int{"c": 2})).To(BeNil())
+		Expect(q.Remove("a")).To(BeNil())
+
+		Expect(written()).To(Equal([]string{
+			"PUT /q/a.json 1",
+			`PATCH /q/b.json {"c":2}`,
+			"DELETE /q/a.json null",
+		}))
+		Expect(q.Status().Depth).To(Equal(0))
+	})
+
+	It("Queues writes while offline, and replays them in order", func() {
+		setOffline(true)
+
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour))
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		Expect(q.Set("a", 1)).To(BeNil())
+		Expect(q.Remove("b")).To(BeNil())
+
+		status := q.Status()
+		Expect(status.Depth).To(Equal(2))
+		Expect(status.Oldest).NotTo(BeZero())
+
+		Expect(q.Replay()).To(HaveOccurred())
+		Expect(q.Status().LastError).To(HaveOccurred())
+
+		setOffline(false)
+
+		// Writes are queued behind the waiting ones.
+		Expect(q.Set("a", 2)).To(BeNil())
+		Expect(written()).To(BeEmpty())
+
+		Expect(q.Replay()).To(BeNil())
+		Expect(written()).To(Equal([]string{
+			"PUT /q/a.json 1",
+			"DELETE /q/b.json null",
+			"PUT /q/a.json 2",
+		}))
+
+		status = q.Status()
+		Expect(status.Depth).To(Equal(0))
+		Expect(status.LastError).To(BeNil())
+
+		contents, err := os.ReadFile(journalPath)
+		Expect(err).To(BeNil())
+		Expect(contents).To(BeEmpty())
+	})
+
+	It("Replays in the background once Firebase is reachable", func() {
+		setOffline(true)
+
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath,
+			ReplayInterval(10*time.Millisecond))
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		Expect(q.Set("a", 1)).To(BeNil())
+		setOffline(false)
+
+		Eventually(written).Should(Equal([]string{"PUT /q/a.json 1"}))
+		Eventually(func() int { return q.Status().Depth }).Should(Equal(0))
+	})
+
+	It("Keeps the mutations that weren't replayed in the journal", func() {
+		setOffline(true)
+
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour))
+		Expect(err).To(BeNil())
+
+		Expect(q.Set("a", 1)).To(BeNil())
+		Expect(q.Set("b", 2)).To(BeNil())
+		Expect(q.Close()).To(BeNil())
+		Expect(q.Set("c", 3)).To(Equal(ErrQueueClosed))
+
+		// A cut short line, as left by a crash while appending.
+		journal, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+		Expect(err).To(BeNil())
+		journal.WriteString(`{"mutation":{"id":3,"op":"se`)
+		journal.Close()
+
+		setOffline(false)
+
+		var replayed []string
+		q, err = NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour),
+			OnReplay(func(mutation Mutation, err error) {
+				Expect(err).To(BeNil())
+				replayed = append(replayed, mutation.Path)
+			}))
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		Eventually(written).Should(Equal([]string{"PUT /q/a.json 1", "PUT /q/b.json 2"}))
+		Eventually(func() int { return q.Status().Depth }).Should(Equal(0))
+		Expect(q.Replay()).To(BeNil())
+		Expect(replayed).To(Equal([]string{"a", "b"}))
+	})
+
+	It("Skips mutations of locations that changed, with SkipIfChanged", func() {
+		var skipped []error
+
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour),
+			WithConflictPolicy(SkipIfChanged),
+			OnReplay(func(mutation Mutation, err error) {
+				skipped = append(skipped, err)
+			}))
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		// Written online, so its ETag is known.
+		Expect(q.Set("a", 1)).To(BeNil())
+
+		setOffline(true)
+		Expect(q.Set("a", 2)).To(BeNil())
+		Expect(q.Set("b", 2)).To(BeNil())
+
+		// Someone else changes the location while we're offline.
+		mu.Lock()
+		etag = "etag-other"
+		mu.Unlock()
+		setOffline(false)
+
+		Expect(q.Replay()).To(BeNil())
+		Expect(written()).To(Equal([]string{"PUT /q/a.json 1", "PUT /q/b.json 2"}))
+		Expect(skipped).To(Equal([]error{ErrConflictSkipped, nil}))
+	})
+
+	It("Compares queued writes of a location with the ETag left by the previous one", func() {
+		var replayed []error
+
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour),
+			WithConflictPolicy(SkipIfChanged),
+			OnReplay(func(mutation Mutation, err error) {
+				replayed = append(replayed, err)
+			}))
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		Expect(q.Set("a", 1)).To(BeNil())
+
+		setOffline(true)
+		Expect(q.Set("a", 2)).To(BeNil())
+		Expect(q.Set("a", 3)).To(BeNil())
+		setOffline(false)
+
+		Expect(q.Replay()).To(BeNil())
+		Expect(written()).To(Equal([]string{"PUT /q/a.json 1", "PUT /q/a.json 2", "PUT /q/a.json 3"}))
+		Expect(replayed).To(Equal([]error{nil, nil}))
+	})
+
+	It("Keeps comparing with the ETags left by replays after a restart", func() {
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour),
+			WithConflictPolicy(SkipIfChanged))
+		Expect(err).To(BeNil())
+
+		Expect(q.Set("a", 1)).To(BeNil())
+		setOffline(true)
+		Expect(q.Set("a", 2)).To(BeNil())
+		Expect(q.Set("a", 3)).To(BeNil())
+		Expect(q.Close()).To(BeNil())
+
+		// The first mutation was replayed before the process died.
+		mu.Lock()
+		etag = "etag-replayed"
+		mu.Unlock()
+		journal, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+		Expect(err).To(BeNil())
+		Expect(writeRecord(journal, journalRecord{Done: 1, Wrote: true, ETag: "etag-replayed"})).To(Succeed())
+		journal.Close()
+		setOffline(false)
+
+		q, err = NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour),
+			WithConflictPolicy(SkipIfChanged))
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		Eventually(func() int { return q.Status().Depth }).Should(Equal(0))
+		Expect(written()).To(Equal([]string{"PUT /q/a.json 1", "PUT /q/a.json 3"}))
+	})
+
+	It("Asks the policy again when the location changes before the write", func() {
+		var asked []string
+
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath, ReplayInterval(time.Hour),
+			WithConflictPolicy(func(mutation Mutation, current string) bool {
+				asked = append(asked, current)
+				return true
+			}))
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		Expect(q.Set("a", 1)).To(BeNil())
+		setOffline(true)
+		Expect(q.Set("a", 2)).To(BeNil())
+
+		mu.Lock()
+		etag = "etag-other"
+		mu.Unlock()
+		setOffline(false)
+
+		Expect(q.Replay()).To(BeNil())
+		Expect(asked).To(Equal([]string{"etag-other"}))
+		Expect(written()).To(Equal([]string{"PUT /q/a.json 1", "PUT /q/a.json 2"}))
+	})
+
+	It("Returns errors other than network errors", func() {
+		q, err := NewOfflineQueue(testClient.Child("q"), journalPath)
+		Expect(err).To(BeNil())
+		defer q.Close()
+
+		err = q.Set("a", func() {})
+		Expect(err).To(HaveOccurred())
+		Expect(strings.Contains(err.Error(), "unsupported type")).To(BeTrue())
+		Expect(q.Status().Depth).To(Equal(0))
+	})
+})
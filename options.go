@@ -0,0 +1,25 @@
+package firebase
+
+// ClientOption configures a client created by NewClient.
+type ClientOption func(*client)
+
+// WithCodec makes the client use codec to marshal and unmarshal values,
+// instead of encoding/json. The codec is used by the default Api
+// implementation for the bodies of requests and responses, and by the client
+// to decode Watch events and ordered results. Api implementations passed to
+// NewClient do their own marshalling.
+func WithCodec(codec Codec) ClientOption {
+	return func(c *client) {
+		c.codec = codec
+	}
+}
+
+// WithInterceptors adds interceptors around the calls and streams of the
+// default Api implementation, in the order given: the first one sees requests
+// first, and results last. Api implementations passed to NewClient are not
+// intercepted.
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(c *client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
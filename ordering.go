@@ -0,0 +1,373 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyValue is a single child of a location, as returned by the ordered read
+// methods (Children and ValueOrdered).
+type KeyValue struct {
+	// Key is the child's key.
+	Key string
+
+	// Value is the child's unparsed JSON value.
+	Value json.RawMessage
+
+	// Priority is the child's priority: nil, a json.Number or a string. It
+	// is only read when the children are ordered by priority, which is
+	// Firebase's default order.
+	Priority interface{}
+
+	// codec is the codec of the client the child was read by. If nil, the
+	// default codec is used.
+	codec Codec
+}
+
+// Unmarshal decodes the child's value into destination, with the codec of
+// the client it was read by.
+func (kv KeyValue) Unmarshal(destination interface{}) error {
+	codec := kv.codec
+	if codec == nil {
+		codec = defaultCodec
+	}
+
+	return codec.Unmarshal(kv.Value, destination)
+}
+
+// integerKey matches the keys Firebase treats as numbers when ordering by
+// key. Only keys in the 32-bit integer range qualify.
+var integerKey = regexp.MustCompile(`^-?(0*)\d{1,10}$`)
+
+// parseIntegerKey returns the numeric value of key and whether Firebase orders
+// it as a number.
+func parseIntegerKey(key string) (int64, bool) {
+	if !integerKey.MatchString(key) {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(key, 10, 64)
+	if err != nil || n < math.MinInt32 || n > math.MaxInt32 {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// compareKeys orders two keys the way Firebase does: keys that parse as
+// 32-bit integers come first in numeric order, followed by all other keys in
+// lexicographic order.
+func compareKeys(a, b string) int {
+	aInt, aIsInt := parseIntegerKey(a)
+	bInt, bIsInt := parseIntegerKey(b)
+
+	switch {
+	case aIsInt && bIsInt:
+		if aInt != bInt {
+			if aInt < bInt {
+				return -1
+			}
+			return 1
+		}
+		// "1" and "01" are the same number, the shorter key comes first.
+		return len(a) - len(b)
+	case aIsInt:
+		return -1
+	case bIsInt:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// Ranks of the JSON types, in the order Firebase sorts them when ordering by
+// value, by child or by priority.
+const (
+	rankNull = iota
+	rankFalse
+	rankTrue
+	rankNumber
+	rankString
+	rankObject
+)
+
+// sortValue is the part of a child's value that determines its position.
+type sortValue struct {
+	rank   int
+	number float64
+	str    string
+}
+
+func newSortValue(value interface{}) sortValue {
+	switch v := value.(type) {
+	case nil:
+		return sortValue{rank: rankNull}
+	case bool:
+		if v {
+			return sortValue{rank: rankTrue}
+		}
+		return sortValue{rank: rankFalse}
+	case json.Number:
+		n, _ := v.Float64()
+		return sortValue{rank: rankNumber, number: n}
+	case string:
+		return sortValue{rank: rankString, str: v}
+	}
+
+	return sortValue{rank: rankObject}
+}
+
+// compareValues orders two values the way Firebase does: nulls, then false,
+// then true, then numbers in ascending order, then strings in lexicographic
+// order, and finally objects. Objects compare as equal, so they fall back to
+// being ordered by key.
+func compareValues(a, b sortValue) int {
+	if a.rank != b.rank {
+		return a.rank - b.rank
+	}
+
+	switch a.rank {
+	case rankNumber:
+		switch {
+		case a.number < b.number:
+			return -1
+		case a.number > b.number:
+			return 1
+		}
+	case rankString:
+		return strings.Compare(a.str, b.str)
+	}
+
+	return 0
+}
+
+// orderedValue extracts the value that a child is ordered by, given the
+// query's orderBy property. Children that lack the property order as null.
+func orderedValue(order string, child interface{}) interface{} {
+	object, isObject := child.(map[string]interface{})
+
+	switch order {
+	case ValueProp:
+		if isObject {
+			if value, ok := object[".value"]; ok {
+				return value
+			}
+		}
+		return child
+	}
+
+	for _, key := range strings.Split(order, "/") {
+		if key == "" {
+			continue
+		}
+
+		if !isObject {
+			return nil
+		}
+
+		child = object[key]
+		object, isObject = child.(map[string]interface{})
+	}
+
+	return child
+}
+
+// childSortValues returns the value each child is ordered by, given the
+// query's orderBy property.
+func childSortValues(order string, children []KeyValue) (map[string]sortValue, error) {
+	values := make(map[string]sortValue, len(children))
+	for _, child := range children {
+		if order == PriorityProp {
+			values[child.Key] = newSortValue(child.Priority)
+			continue
+		}
+
+		var parsed interface{}
+
+		decoder := json.NewDecoder(bytes.NewReader(child.Value))
+		decoder.UseNumber()
+		if err := decoder.Decode(&parsed); err != nil {
+			return nil, err
+		}
+
+		values[child.Key] = newSortValue(orderedValue(order, parsed))
+	}
+
+	return values, nil
+}
+
+// sortChildren sorts children in place, in the order Firebase returns them for
+// the given orderBy property. An empty order is Firebase's default ordering,
+// which is by priority. Ties are always broken by key.
+func sortChildren(order string, children []KeyValue) error {
+	if order == "" {
+		order = PriorityProp
+	}
+
+	if order == KeyProp {
+		sort.SliceStable(children, func(i, j int) bool {
+			return compareKeys(children[i].Key, children[j].Key) < 0
+		})
+		return nil
+	}
+
+	values, err := childSortValues(order, children)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(children, func(i, j int) bool {
+		a, b := children[i], children[j]
+		if cmp := compareValues(values[a.Key], values[b.Key]); cmp != 0 {
+			return cmp < 0
+		}
+		return compareKeys(a.Key, b.Key) < 0
+	})
+
+	return nil
+}
+
+// orderedChildren reads the children of the client's location, filtered by
+// the given query params, and sorts them by order.
+func (c *client) orderedChildren(order string, params map[string]string) ([]KeyValue, error) {
+	var children []KeyValue
+	var err error
+
+	if order == "" || order == PriorityProp {
+		children, err = c.childrenWithPriorities(params)
+	} else {
+		children, err = c.childrenWithoutPriorities(params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sortChildren(order, children); err != nil {
+		return nil, err
+	}
+
+	return children, nil
+}
+
+// childrenWithoutPriorities reads the children of the client's location,
+// filtered by the given query params.
+func (c *client) childrenWithoutPriorities(params map[string]string) ([]KeyValue, error) {
+	var raw map[string]json.RawMessage
+
+	if err := c.value(params, &raw); err != nil {
+		return nil, err
+	}
+
+	children := make([]KeyValue, 0, len(raw))
+	for key, value := range raw {
+		children = append(children, KeyValue{Key: key, Value: value, codec: c.codec})
+	}
+
+	return children, nil
+}
+
+// childrenWithPriorities reads the children of the client's location, filtered
+// by the given query params, along with their priorities. Firebase only sends
+// priorities in its export format, which is unwrapped from the values.
+func (c *client) childrenWithPriorities(params map[string]string) ([]KeyValue, error) {
+	var nodes map[string]*Node
+
+	if err := c.value(exportParams(params), &nodes); err != nil {
+		return nil, err
+	}
+
+	children := make([]KeyValue, 0, len(nodes))
+	for key, node := range nodes {
+		var value bytes.Buffer
+		if err := node.writeJSON(&value, false); err != nil {
+			return nil, err
+		}
+
+		children = append(children, KeyValue{
+			Key:      key,
+			Value:    value.Bytes(),
+			Priority: node.Priority,
+			codec:    c.codec,
+		})
+	}
+
+	return children, nil
+}
+
+// unmarshalOrdered decodes each child's value into a new element of the slice
+// pointed to by destination with codec, preserving the order of children.
+func unmarshalOrdered(codec Codec, children []KeyValue, destination interface{}) error {
+	slicePtr := reflect.ValueOf(destination)
+	if slicePtr.Kind() != reflect.Ptr || slicePtr.Elem().Kind() != reflect.Slice {
+		return errors.New("firebase: ordered destination must be a pointer to a slice")
+	}
+
+	slice := slicePtr.Elem()
+	result := reflect.MakeSlice(slice.Type(), 0, len(children))
+
+	for _, child := range children {
+		element := reflect.New(slice.Type().Elem())
+		if err := codec.Unmarshal(child.Value, element.Interface()); err != nil {
+			return err
+		}
+
+		result = reflect.Append(result, element.Elem())
+	}
+
+	slice.Set(result)
+	return nil
+}
+
+func (c *client) Children() ([]KeyValue, error) {
+	return c.orderedChildren("", nil)
+}
+
+func (c *client) ValueOrdered(destination interface{}) error {
+	children, err := c.Children()
+	if err != nil {
+		return err
+	}
+
+	return unmarshalOrdered(c.codec, children, destination)
+}
+
+func (q *query) Children() ([]KeyValue, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	children, err := q.ref.orderedChildren(q.order, q.params)
+	if err != nil || (q.start == nil && q.end == nil) {
+		return children, err
+	}
+
+	values, err := childSortValues(q.order, children)
+	if err != nil {
+		return nil, err
+	}
+
+	inRange := children[:0]
+	for _, child := range children {
+		if q.inRange(child.Key, values[child.Key]) {
+			inRange = append(inRange, child)
+		}
+	}
+
+	return inRange, nil
+}
+
+func (q *query) ValueOrdered(destination interface{}) error {
+	children, err := q.Children()
+	if err != nil {
+		return err
+	}
+
+	return unmarshalOrdered(q.ref.codec, children, destination)
+}
@@ -0,0 +1,169 @@
+package firebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func childrenOf(object string) []KeyValue {
+	var raw map[string]json.RawMessage
+	Expect(json.Unmarshal([]byte(object), &raw)).To(Succeed())
+
+	children := []KeyValue{}
+	for key, value := range raw {
+		children = append(children, KeyValue{Key: key, Value: value})
+	}
+	return children
+}
+
+func keysOf(children []KeyValue) []string {
+	keys := make([]string, len(children))
+	for i, child := range children {
+		keys[i] = child.Key
+	}
+	return keys
+}
+
+var _ = Describe("Ordering query results", func() {
+	It("Orders integer keys numerically before other keys", func() {
+		children := childrenOf(`{"b": 1, "10": 1, "a": 1, "2": 1, "-1": 1,
+			"9999999999": 1, "02": 1}`)
+		Expect(sortChildren("$key", children)).To(Succeed())
+		Expect(keysOf(children)).To(Equal(
+			[]string{"-1", "2", "02", "10", "9999999999", "a", "b"}))
+	})
+
+	It("Orders values by type, then value, then key", func() {
+		children := childrenOf(`{"obj": {"a": 1}, "s": "abc", "t": true,
+			"f": false, "n": null, "big": 100, "small": -5.5, "s2": "abd",
+			"t2": true}`)
+		Expect(sortChildren("$value", children)).To(Succeed())
+		Expect(keysOf(children)).To(Equal([]string{"n", "f", "t", "t2",
+			"small", "big", "s", "s2", "obj"}))
+	})
+
+	It("Orders by a nested child, missing children first", func() {
+		children := childrenOf(`{
+			"stego":    {"stats": {"score": 10}},
+			"pterodon": {"stats": {}},
+			"rex":      {"stats": {"score": 50}},
+			"bronto":   {"stats": {"score": 10}},
+			"lambeo":   "leaf"}`)
+		Expect(sortChildren("stats/score", children)).To(Succeed())
+		Expect(keysOf(children)).To(Equal(
+			[]string{"lambeo", "pterodon", "bronto", "stego", "rex"}))
+	})
+
+	It("Orders by priority, then key, by default", func() {
+		children := []KeyValue{
+			{Key: "c", Priority: "x"},
+			{Key: "b", Priority: json.Number("5")},
+			{Key: "z"},
+			{Key: "y", Priority: json.Number("5")},
+			{Key: "a", Priority: json.Number("1")},
+		}
+		Expect(sortChildren("", children)).To(Succeed())
+		Expect(keysOf(children)).To(Equal([]string{"z", "a", "b", "y", "c"}))
+	})
+})
+
+var _ = Describe("Reading ordered query results", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+	)
+
+	BeforeEach(func() {
+		testServer, testClient = fakeServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("orderBy")).To(Equal(`"$value"`))
+				fmt.Fprintln(w, `{"bruhathkayosaurus": 55, "linhenykus": 80,
+					"pterodactyl": 93, "stegosaurus": 5}`)
+			}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("Returns the children sorted by value", func() {
+		scores := testClient.Child("scores").OrderBy("$value").LimitToLast(4)
+
+		children, err := scores.Children()
+		Expect(err).To(BeNil())
+		Expect(keysOf(children)).To(Equal([]string{"stegosaurus",
+			"bruhathkayosaurus", "linhenykus", "pterodactyl"}))
+
+		var values []int
+		Expect(scores.ValueOrdered(&values)).To(Succeed())
+		Expect(values).To(Equal([]int{5, 55, 80, 93}))
+	})
+
+	It("Requires a pointer to a slice", func() {
+		var values map[string]int
+		err := testClient.OrderBy("$value").ValueOrdered(&values)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Reading children ordered by priority", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		query      map[string][]string
+	)
+
+	BeforeEach(func() {
+		testServer, testClient = fakeServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Query()
+				if r.URL.Query().Get("format") != "export" {
+					// Firebase leaves priorities out of regular reads.
+					fmt.Fprintln(w, `{"a": {"v": 3}, "b": 2, "c": {"v": 1}}`)
+					return
+				}
+
+				fmt.Fprintln(w, `{
+					"a": {".priority": "x", "v": 3},
+					"b": {".value": 2, ".priority": 1},
+					"c": {"v": {".value": 1, ".priority": 7}}}`)
+			}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("Reads priorities in the export format, and unwraps them", func() {
+		children, err := testClient.OrderByPriority().Children()
+		Expect(err).To(BeNil())
+		Expect(query["format"]).To(Equal([]string{"export"}))
+		Expect(query["orderBy"]).To(Equal([]string{`"$priority"`}))
+
+		Expect(keysOf(children)).To(Equal([]string{"c", "b", "a"}))
+		Expect(children[0].Value).To(MatchJSON(`{"v": 1}`))
+		Expect(children[0].Priority).To(BeNil())
+		Expect(children[1].Value).To(MatchJSON(`2`))
+		Expect(children[1].Priority).To(Equal(json.Number("1")))
+		Expect(children[2].Value).To(MatchJSON(`{"v": 3}`))
+		Expect(children[2].Priority).To(Equal("x"))
+	})
+
+	It("Orders the children of a reference by priority", func() {
+		children, err := testClient.Children()
+		Expect(err).To(BeNil())
+		Expect(query["format"]).To(Equal([]string{"export"}))
+		Expect(keysOf(children)).To(Equal([]string{"c", "b", "a"}))
+	})
+
+	It("Reads other orders without priorities", func() {
+		_, err := testClient.OrderByKey().Children()
+		Expect(err).To(BeNil())
+		Expect(query).NotTo(HaveKey("format"))
+	})
+})
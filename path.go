@@ -0,0 +1,172 @@
+package firebase
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxKeyBytes is the longest key, in UTF-8 encoded bytes, that Firebase
+	// accepts.
+	maxKeyBytes = 768
+
+	// maxPathDepth is the deepest a location may be nested under the
+	// database root.
+	maxPathDepth = 32
+
+	// forbiddenKeyChars are the characters Firebase does not allow in keys.
+	// ASCII control characters are also forbidden.
+	forbiddenKeyChars = ".$#[]/"
+)
+
+// InvalidPathError is returned when a path or key does not satisfy Firebase's
+// rules for keys. It is always returned before any request is sent.
+type InvalidPathError struct {
+	// Path is the offending path or key, as given by the caller.
+	Path string
+
+	// Reason describes which rule was violated.
+	Reason string
+}
+
+func (e *InvalidPathError) Error() string {
+	return fmt.Sprintf("firebase: invalid path %q: %s", e.Path, e.Reason)
+}
+
+// Path is a location in a Firebase database, stored as its individual keys
+// (segments). The zero value is the database root.
+type Path []string
+
+// ParsePath splits a slash separated path into its keys and validates each of
+// them. Leading, trailing and repeated slashes are ignored, so "", "/" and
+// "//" all refer to the root.
+func ParsePath(p string) (Path, error) {
+	var path Path
+
+	for _, key := range strings.Split(p, "/") {
+		if key == "" {
+			continue
+		}
+
+		if reason := keyProblem(key); reason != "" {
+			return nil, &InvalidPathError{Path: p, Reason: reason}
+		}
+
+		path = append(path, key)
+	}
+
+	if len(path) > maxPathDepth {
+		return nil, &InvalidPathError{
+			Path:   p,
+			Reason: fmt.Sprintf("deeper than %d levels", maxPathDepth),
+		}
+	}
+
+	return path, nil
+}
+
+// ValidateKey reports whether key may be used as a single segment of a
+// Firebase path. Keys must be non-empty valid UTF-8 of at most 768 bytes, and
+// may not contain ".", "$", "#", "[", "]", "/" or ASCII control characters.
+func ValidateKey(key string) error {
+	if reason := keyProblem(key); reason != "" {
+		return &InvalidPathError{Path: key, Reason: reason}
+	}
+
+	return nil
+}
+
+// keyProblem returns the reason key is not a valid Firebase key, or "" if it
+// is valid.
+func keyProblem(key string) string {
+	switch {
+	case key == "":
+		return "empty key"
+	case len(key) > maxKeyBytes:
+		return fmt.Sprintf("key longer than %d bytes", maxKeyBytes)
+	case !utf8.ValidString(key):
+		return "key is not valid UTF-8"
+	}
+
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f {
+			return "key contains a control character"
+		}
+
+		if strings.ContainsRune(forbiddenKeyChars, r) {
+			return fmt.Sprintf("key contains forbidden character %q", r)
+		}
+	}
+
+	return ""
+}
+
+// Child returns a new path with the keys of child appended to p. It never
+// modifies p.
+func (p Path) Child(child Path) Path {
+	joined := make(Path, 0, len(p)+len(child))
+	joined = append(joined, p...)
+	return append(joined, child...)
+}
+
+// Key returns the last key of the path, or "" for the root.
+func (p Path) Key() string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	return p[len(p)-1]
+}
+
+// String returns the keys of the path joined by slashes, without escaping.
+func (p Path) String() string {
+	return strings.Join(p, "/")
+}
+
+// Escaped returns the path in a form suitable for a URL, with each key
+// escaped individually.
+func (p Path) Escaped() string {
+	escaped := make([]string, len(p))
+	for i, key := range p {
+		escaped[i] = url.PathEscape(key)
+	}
+
+	return strings.Join(escaped, "/")
+}
+
+// childPath parses a path relative to base and checks the depth of the
+// resulting location.
+func childPath(base Path, relative string) (Path, error) {
+	child, err := ParsePath(relative)
+	if err != nil {
+		return nil, err
+	}
+
+	joined := base.Child(child)
+	if len(joined) > maxPathDepth {
+		return nil, &InvalidPathError{
+			Path:   joined.String(),
+			Reason: fmt.Sprintf("deeper than %d levels", maxPathDepth),
+		}
+	}
+
+	return joined, nil
+}
+
+// splitRoot separates a database URL into its root (scheme and host) and the
+// path of the location it refers to.
+func splitRoot(rawURL string) (string, Path, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return strings.TrimRight(redactURL(rawURL), "/"), nil, redactError(err)
+	}
+
+	path, err := ParsePath(u.Path)
+	if err != nil {
+		return u.Scheme + "://" + u.Host, nil, err
+	}
+
+	return u.Scheme + "://" + u.Host, path, nil
+}
@@ -0,0 +1,57 @@
+package firebase
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Firebase paths", func() {
+	It("Splits a path into its keys, ignoring extra slashes", func() {
+		path, err := ParsePath("/a//b/c/")
+		Expect(err).To(BeNil())
+		Expect(path).To(Equal(Path{"a", "b", "c"}))
+		Expect(path.Key()).To(Equal("c"))
+		Expect(path.String()).To(Equal("a/b/c"))
+	})
+
+	It("Treats an empty path as the root", func() {
+		path, err := ParsePath("/")
+		Expect(err).To(BeNil())
+		Expect(path).To(BeEmpty())
+		Expect(path.Key()).To(Equal(""))
+	})
+
+	It("Escapes each key individually", func() {
+		path, err := ParsePath("users/jane doe/100%?/日本")
+		Expect(err).To(BeNil())
+		Expect(path.Escaped()).To(Equal(
+			"users/jane%20doe/100%25%3F/%E6%97%A5%E6%9C%AC"))
+	})
+
+	It("Rejects keys with forbidden characters", func() {
+		for _, key := range []string{"a.b", "$a", "a#", "[a", "a]", "a\x01", "a\x7f"} {
+			Expect(ValidateKey(key)).To(HaveOccurred(), key)
+
+			_, err := ParsePath("parent/" + key)
+			Expect(err).To(BeAssignableToTypeOf(&InvalidPathError{}), key)
+		}
+	})
+
+	It("Rejects keys longer than 768 bytes", func() {
+		Expect(ValidateKey(strings.Repeat("a", 768))).To(BeNil())
+		Expect(ValidateKey(strings.Repeat("a", 769))).To(HaveOccurred())
+	})
+
+	It("Rejects paths deeper than 32 levels", func() {
+		_, err := ParsePath(strings.Repeat("a/", 32))
+		Expect(err).To(BeNil())
+
+		_, err = ParsePath(strings.Repeat("a/", 33))
+		Expect(err).To(HaveOccurred())
+
+		_, err = childPath(Path{"a", "b"}, strings.Repeat("a/", 31))
+		Expect(err).To(HaveOccurred())
+	})
+})
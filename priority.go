@@ -0,0 +1,304 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// errInvalidPriority is returned when a priority is not null, a number or a
+// string.
+var errInvalidPriority = errors.New("firebase: a priority must be null, a number or a string")
+
+// encodePriority marshals a priority, and checks that it is a valid one.
+func encodePriority(codec Codec, priority interface{}) ([]byte, error) {
+	encoded, err := codec.Marshal(priority)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded = bytes.TrimSpace(encoded)
+	if len(encoded) == 0 {
+		return nil, errInvalidPriority
+	}
+
+	switch c := encoded[0]; {
+	case c == 'n', c == '"', c == '-', c >= '0' && c <= '9':
+		return encoded, nil
+	}
+
+	return nil, errInvalidPriority
+}
+
+// withPriority returns the JSON of value with a priority attached, the way the
+// REST API expects it: as a ".priority" key of objects, or wrapped along with
+// a ".value" key for everything else.
+func withPriority(codec Codec, value, priority interface{}) (json.RawMessage, error) {
+	encodedPriority, err := encodePriority(codec, priority)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedValue, err := codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	encodedValue = bytes.TrimSpace(encodedValue)
+
+	var buf bytes.Buffer
+	buf.WriteString(`{".priority":`)
+	buf.Write(encodedPriority)
+
+	if len(encodedValue) > 0 && encodedValue[0] == '{' {
+		rest := bytes.TrimSpace(encodedValue[1:])
+		if rest[0] != '}' {
+			buf.WriteByte(',')
+		}
+		buf.Write(rest)
+	} else {
+		buf.WriteString(`,".value":`)
+		buf.Write(encodedValue)
+		buf.WriteByte('}')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// specialURL is the URL of one of the REST API's special children (such as
+// .priority) of the client's location.
+func (c *client) specialURL(name string) string {
+	return strings.TrimSuffix(c.url, "/") + "/" + name
+}
+
+func (c *client) SetWithPriority(path string, value, priority interface{}, options ...WriteOption) (Reference, error) {
+	newC, err := c.child(path)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := newWriteOptions(nil, options)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := withPriority(c.codec, value, priority)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.call("PUT", newC.url, body, opts.params, opts.destination(c.codec))
+	if err != nil {
+		return nil, err
+	}
+
+	return newC, nil
+}
+
+func (c *client) SetPriority(priority interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	encoded, err := encodePriority(c.codec, priority)
+	if err != nil {
+		return err
+	}
+
+	return c.call("PUT", c.specialURL(".priority"), json.RawMessage(encoded),
+		nil, nil)
+}
+
+// Node is a value read in Firebase's export format, which keeps the priority
+// of every value. It is returned by ValueExport. A Node is either a leaf,
+// with a Value, or has Children.
+//
+// A Node marshals back to the export format, so it can be written with Set to
+// restore both the values and their priorities.
+type Node struct {
+	// Value is the raw JSON of a leaf value. It is nil if the node has
+	// children.
+	Value json.RawMessage
+
+	// Priority is the node's priority: nil, a json.Number or a string.
+	Priority interface{}
+
+	// Children are the node's children, by key.
+	Children map[string]*Node
+
+	// codec is the codec of the client the node was read by. If nil, the
+	// default codec is used.
+	codec Codec
+}
+
+func (n *Node) getCodec() Codec {
+	if n.codec == nil {
+		return defaultCodec
+	}
+
+	return n.codec
+}
+
+// setCodec sets the codec of the node and of its descendants.
+func (n *Node) setCodec(codec Codec) {
+	n.codec = codec
+	for _, child := range n.Children {
+		child.setCodec(codec)
+	}
+}
+
+func (n *Node) UnmarshalJSON(b []byte) error {
+	var object map[string]json.RawMessage
+
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '{' {
+		*n = Node{Value: append(json.RawMessage(nil), b...)}
+		return nil
+	}
+
+	if err := json.Unmarshal(b, &object); err != nil {
+		return err
+	}
+
+	node := Node{}
+
+	if rawPriority, ok := object[".priority"]; ok {
+		decoder := json.NewDecoder(bytes.NewReader(rawPriority))
+		decoder.UseNumber()
+		if err := decoder.Decode(&node.Priority); err != nil {
+			return err
+		}
+		delete(object, ".priority")
+	}
+
+	if value, ok := object[".value"]; ok {
+		node.Value = value
+		*n = node
+		return nil
+	}
+
+	node.Children = make(map[string]*Node, len(object))
+	for key, rawChild := range object {
+		child := &Node{}
+		if err := child.UnmarshalJSON(rawChild); err != nil {
+			return err
+		}
+		node.Children[key] = child
+	}
+
+	*n = node
+	return nil
+}
+
+// writeJSON writes the node's value, with or without priorities.
+func (n *Node) writeJSON(buf *bytes.Buffer, priorities bool) error {
+	hasPriority := priorities && n.Priority != nil
+
+	var encodedPriority []byte
+	if hasPriority {
+		var err error
+		if encodedPriority, err = encodePriority(n.getCodec(), n.Priority); err != nil {
+			return err
+		}
+	}
+
+	if n.Children == nil {
+		value := n.Value
+		if value == nil {
+			value = json.RawMessage("null")
+		}
+
+		if !hasPriority {
+			buf.Write(value)
+			return nil
+		}
+
+		buf.WriteString(`{".value":`)
+		buf.Write(value)
+		buf.WriteString(`,".priority":`)
+		buf.Write(encodedPriority)
+		buf.WriteByte('}')
+		return nil
+	}
+
+	keys := make([]string, 0, len(n.Children))
+	for key := range n.Children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('{')
+	if hasPriority {
+		buf.WriteString(`".priority":`)
+		buf.Write(encodedPriority)
+	}
+
+	for i, key := range keys {
+		if i > 0 || hasPriority {
+			buf.WriteByte(',')
+		}
+
+		encodedKey, _ := json.Marshal(key)
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		if err := n.Children[key].writeJSON(buf, priorities); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+
+	return nil
+}
+
+func (n *Node) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := n.writeJSON(&buf, true); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the node's value, without any priorities, into
+// destination, with the codec of the client it was read by.
+func (n *Node) Unmarshal(destination interface{}) error {
+	var buf bytes.Buffer
+	if err := n.writeJSON(&buf, false); err != nil {
+		return err
+	}
+
+	return n.getCodec().Unmarshal(buf.Bytes(), destination)
+}
+
+// exportParams returns params with the export format requested.
+func exportParams(params map[string]string) map[string]string {
+	export := make(map[string]string, len(params)+1)
+	for key, value := range params {
+		export[key] = value
+	}
+	export["format"] = "export"
+	return export
+}
+
+func (c *client) ValueExport(destination *Node) error {
+	if err := c.value(exportParams(nil), destination); err != nil {
+		return err
+	}
+
+	destination.setCodec(c.codec)
+	return nil
+}
+
+func (q *query) ValueExport(destination *Node) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	if err := q.ref.value(exportParams(q.params), destination); err != nil {
+		return err
+	}
+
+	destination.setCodec(q.ref.codec)
+	return nil
+}
@@ -0,0 +1,108 @@
+package firebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Priorities", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		handler    func(w http.ResponseWriter, r *http.Request)
+	)
+
+	JustBeforeEach(func() {
+		testServer, testClient = fakeServer(http.HandlerFunc(handler))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	Context("Writing", func() {
+		var (
+			paths  []string
+			bodies []string
+		)
+
+		BeforeEach(func() {
+			paths, bodies = nil, nil
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal("PUT"))
+				body, err := io.ReadAll(r.Body)
+				Expect(err).To(BeNil())
+
+				paths = append(paths, r.URL.Path)
+				bodies = append(bodies, string(body))
+			}
+		})
+
+		It("Adds the priority to objects", func() {
+			_, err := testClient.SetWithPriority("a", Widget{A: 1}, 10)
+			Expect(err).To(BeNil())
+			Expect(bodies).To(Equal([]string{`{".priority":10,"A":1}`}))
+		})
+
+		It("Wraps other values along with their priority", func() {
+			ref, err := testClient.SetWithPriority("a/b", "text", "high")
+			Expect(err).To(BeNil())
+			Expect(ref.Path()).To(Equal(Path{"a", "b"}))
+			Expect(bodies).To(Equal([]string{`{".priority":"high",".value":"text"}`}))
+		})
+
+		It("Rejects priorities that are not numbers or strings", func() {
+			_, err := testClient.SetWithPriority("a", 1, true)
+			Expect(err).To(HaveOccurred())
+			Expect(testClient.SetPriority(map[string]int{})).NotTo(Succeed())
+			Expect(bodies).To(BeEmpty())
+		})
+
+		It("Sets the priority alone", func() {
+			Expect(testClient.Child("a").SetPriority(3.5)).To(Succeed())
+			Expect(testClient.SetPriority(nil)).To(Succeed())
+			Expect(paths).To(Equal([]string{"/a/.priority.json", "/.priority.json"}))
+			Expect(bodies).To(Equal([]string{"3.5", "null"}))
+		})
+	})
+
+	Context("Reading in export format", func() {
+		BeforeEach(func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("format")).To(Equal("export"))
+				fmt.Fprint(w, `{".priority": "top", "a": {".value": 1, ".priority": 2},
+					"b": {"c": true}}`)
+			}
+		})
+
+		It("Keeps the priorities alongside the values", func() {
+			var node Node
+			Expect(testClient.ValueExport(&node)).To(Succeed())
+
+			Expect(node.Priority).To(Equal("top"))
+			Expect(node.Children).To(HaveLen(2))
+			Expect(string(node.Children["a"].Value)).To(Equal("1"))
+			Expect(node.Children["a"].Priority).To(Equal(json.Number("2")))
+			Expect(node.Children["b"].Priority).To(BeNil())
+			Expect(string(node.Children["b"].Children["c"].Value)).To(Equal("true"))
+
+			var plain map[string]interface{}
+			Expect(node.Unmarshal(&plain)).To(Succeed())
+			Expect(plain).To(Equal(map[string]interface{}{
+				"a": float64(1),
+				"b": map[string]interface{}{"c": true},
+			}))
+
+			exported, err := json.Marshal(&node)
+			Expect(err).To(BeNil())
+			Expect(string(exported)).To(Equal(`{".priority":"top",` +
+				`"a":{".value":1,".priority":2},"b":{"c":true}}`))
+		})
+	})
+})
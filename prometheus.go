@@ -0,0 +1,247 @@
+package firebase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the call latency
+// histogram. They are Prometheus' default buckets.
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusCollector is a MetricsCollector that keeps its measurements in
+// memory, and serves them over HTTP in the Prometheus text exposition format.
+// It has no dependency on the Prometheus client library.
+//
+//	metrics := firebase.NewPrometheusCollector()
+//	client := firebase.NewClient(url, auth, nil, firebase.WithMetrics(metrics))
+//	http.Handle("/metrics", metrics)
+type PrometheusCollector struct {
+	mu            sync.Mutex
+	calls         map[callLabels]*histogram
+	retries       map[string]uint64
+	bytesSent     uint64
+	bytesReceived uint64
+	activeStreams int64
+	events        map[string]uint64
+	reconnects    uint64
+	dropped       map[string]uint64
+}
+
+// callLabels are the labels of the call metrics.
+type callLabels struct {
+	method string
+	status string
+}
+
+// String formats the labels the way they appear between braces.
+func (l callLabels) String() string {
+	return fmt.Sprintf("method=\"%s\",status=\"%s\"", escapeLabel(l.method), escapeLabel(l.status))
+}
+
+// labelEscaper escapes label values for the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// histogram counts observations in cumulative buckets.
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range latencyBuckets {
+		if value <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// NewPrometheusCollector returns an empty PrometheusCollector.
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		calls:   map[callLabels]*histogram{},
+		retries: map[string]uint64{},
+		events:  map[string]uint64{},
+		dropped: map[string]uint64{},
+	}
+}
+
+func (p *PrometheusCollector) CallDone(method string, status int, duration time.Duration) {
+	labels := callLabels{method: method, status: "error"}
+	if status > 0 {
+		labels.status = strconv.Itoa(status)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h, ok := p.calls[labels]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		p.calls[labels] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+func (p *PrometheusCollector) CallRetried(method string) {
+	p.mu.Lock()
+	p.retries[method]++
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) BytesSent(n int64) {
+	p.mu.Lock()
+	p.bytesSent += uint64(n)
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) BytesReceived(n int64) {
+	p.mu.Lock()
+	p.bytesReceived += uint64(n)
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) StreamOpened() {
+	p.mu.Lock()
+	p.activeStreams++
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) StreamClosed() {
+	p.mu.Lock()
+	p.activeStreams--
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) EventReceived(eventType string) {
+	p.mu.Lock()
+	p.events[eventType]++
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) StreamReconnected() {
+	p.mu.Lock()
+	p.reconnects++
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) EventDropped(eventType string) {
+	p.mu.Lock()
+	p.dropped[eventType]++
+	p.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (p *PrometheusCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (p *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	p.mu.Lock()
+
+	header(&b, "firebase_requests_total", "counter",
+		"Calls made to Firebase, by method and final status.")
+	for _, labels := range sortedCallLabels(p.calls) {
+		fmt.Fprintf(&b, "firebase_requests_total{%s} %d\n", labels, p.calls[labels].count)
+	}
+
+	header(&b, "firebase_request_duration_seconds", "histogram",
+		"Latency of calls to Firebase, retries included, by method and final status.")
+	for _, labels := range sortedCallLabels(p.calls) {
+		h := p.calls[labels]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(&b, "firebase_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&b, "firebase_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n",
+			labels, h.count)
+		fmt.Fprintf(&b, "firebase_request_duration_seconds_sum{%s} %s\n",
+			labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "firebase_request_duration_seconds_count{%s} %d\n",
+			labels, h.count)
+	}
+
+	header(&b, "firebase_request_retries_total", "counter",
+		"Attempts of calls to Firebase after the first one, by method.")
+	writeLabeled(&b, "firebase_request_retries_total", "method", p.retries)
+
+	header(&b, "firebase_sent_bytes_total", "counter",
+		"Bytes of request bodies sent to Firebase.")
+	fmt.Fprintf(&b, "firebase_sent_bytes_total %d\n", p.bytesSent)
+
+	header(&b, "firebase_received_bytes_total", "counter",
+		"Bytes of response bodies received from Firebase, streams included.")
+	fmt.Fprintf(&b, "firebase_received_bytes_total %d\n", p.bytesReceived)
+
+	header(&b, "firebase_active_streams", "gauge",
+		"Streams currently open.")
+	fmt.Fprintf(&b, "firebase_active_streams %d\n", p.activeStreams)
+
+	header(&b, "firebase_stream_events_total", "counter",
+		"Events received from streams, by type.")
+	writeLabeled(&b, "firebase_stream_events_total", "type", p.events)
+
+	header(&b, "firebase_stream_reconnects_total", "counter",
+		"Streams reopened by watches.")
+	fmt.Fprintf(&b, "firebase_stream_reconnects_total %d\n", p.reconnects)
+
+	header(&b, "firebase_dropped_events_total", "counter",
+		"Events received from streams that watches did not deliver, by type.")
+	writeLabeled(&b, "firebase_dropped_events_total", "type", p.dropped)
+
+	p.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// header writes the HELP and TYPE lines of a metric.
+func header(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeLabeled writes a counter with a single label, in label order.
+func writeLabeled(b *strings.Builder, name, label string, values map[string]uint64) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(b, "%s{%s=\"%s\"} %d\n", name, label, escapeLabel(key), values[key])
+	}
+}
+
+func sortedCallLabels(calls map[callLabels]*histogram) []callLabels {
+	labels := make([]callLabels, 0, len(calls))
+	for l := range calls {
+		labels = append(labels, l)
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].method != labels[j].method {
+			return labels[i].method < labels[j].method
+		}
+		return labels[i].status < labels[j].status
+	})
+
+	return labels
+}
@@ -0,0 +1,118 @@
+package firebase
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pushIDChars are the characters of push IDs, in ASCII order so that IDs sort
+// the same way as strings as they do as timestamps.
+const pushIDChars = "-0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+const (
+	// pushIDTimeLength is the number of characters that encode the time.
+	pushIDTimeLength = 8
+
+	// pushIDRandomLength is the number of random characters that follow.
+	pushIDRandomLength = 12
+)
+
+// pushIDGenerator generates push IDs the way Firebase's clients do. IDs
+// generated in the same millisecond reuse the previous random characters,
+// incremented by one, so that they still sort in the order they were made.
+type pushIDGenerator struct {
+	mu         sync.Mutex
+	lastTime   int64
+	lastRandom [pushIDRandomLength]int
+}
+
+var pushIDs pushIDGenerator
+
+func (g *pushIDGenerator) generate(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := now.UnixMilli()
+
+	if ms == g.lastTime {
+		g.increment()
+	} else {
+		g.lastTime = ms
+		g.randomize()
+	}
+
+	var id [pushIDTimeLength + pushIDRandomLength]byte
+
+	for i := pushIDTimeLength - 1; i >= 0; i-- {
+		id[i] = pushIDChars[ms%64]
+		ms /= 64
+	}
+
+	for i, r := range g.lastRandom {
+		id[pushIDTimeLength+i] = pushIDChars[r]
+	}
+
+	return string(id[:])
+}
+
+func (g *pushIDGenerator) randomize() {
+	var b [pushIDRandomLength]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("firebase: cannot read random bytes for push ID: %v", err))
+	}
+
+	for i := range g.lastRandom {
+		g.lastRandom[i] = int(b[i] % 64)
+	}
+}
+
+// increment adds one to the random characters, carrying over from the last
+// one.
+func (g *pushIDGenerator) increment() {
+	for i := pushIDRandomLength - 1; i >= 0; i-- {
+		if g.lastRandom[i] < 63 {
+			g.lastRandom[i]++
+			return
+		}
+		g.lastRandom[i] = 0
+	}
+}
+
+// GeneratePushID returns a new key, as generated by Firebase's Push. Keys
+// start with the time they were generated at, so they sort chronologically,
+// followed by random characters that keep keys generated at the same time by
+// different clients apart. Keys generated by the same process in the same
+// millisecond still sort in the order they were generated.
+func GeneratePushID() string {
+	return pushIDs.generate(time.Now())
+}
+
+// PushIDTime returns the time a push ID was generated at, to the millisecond.
+func PushIDTime(id string) (time.Time, error) {
+	if len(id) != pushIDTimeLength+pushIDRandomLength {
+		return time.Time{}, fmt.Errorf("firebase: %q is not a push ID, its length is not %d",
+			id, pushIDTimeLength+pushIDRandomLength)
+	}
+
+	var ms int64
+	for i := 0; i < pushIDTimeLength; i++ {
+		n := strings.IndexByte(pushIDChars, id[i])
+		if n < 0 {
+			return time.Time{}, fmt.Errorf("firebase: %q is not a push ID, it contains %q", id, id[i])
+		}
+		ms = ms*64 + int64(n)
+	}
+
+	return time.UnixMilli(ms), nil
+}
+
+func (c *client) PushIdempotent(value interface{}, params map[string]string, options ...WriteOption) (Reference, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return c.Set(GeneratePushID(), value, params, options...)
+}
@@ -0,0 +1,100 @@
+package firebase
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Push IDs", func() {
+	It("Encodes the time they were generated at", func() {
+		before := time.Now().Truncate(time.Millisecond)
+		id := GeneratePushID()
+		after := time.Now()
+
+		Expect(id).To(HaveLen(20))
+
+		at, err := PushIDTime(id)
+		Expect(err).To(BeNil())
+		Expect(at).To(BeTemporally(">=", before))
+		Expect(at).To(BeTemporally("<=", after))
+	})
+
+	It("Sorts in the order they were generated, even in the same millisecond", func() {
+		generator := &pushIDGenerator{}
+		now := time.UnixMilli(1500000000000)
+
+		ids := []string{}
+		for i := 0; i < 1000; i++ {
+			ids = append(ids, generator.generate(now))
+		}
+		ids = append(ids, generator.generate(now.Add(time.Millisecond)))
+
+		Expect(sort.StringsAreSorted(ids)).To(BeTrue())
+
+		at, err := PushIDTime(ids[0])
+		Expect(err).To(BeNil())
+		Expect(at).To(Equal(now))
+	})
+
+	It("Carries over when incrementing the random characters", func() {
+		generator := &pushIDGenerator{lastTime: 1}
+		for i := range generator.lastRandom {
+			generator.lastRandom[i] = 63
+		}
+		generator.lastRandom[0] = 0
+
+		id := generator.generate(time.UnixMilli(1))
+		Expect(id[8:]).To(Equal("0-----------"))
+	})
+
+	It("Rejects malformed push IDs", func() {
+		_, err := PushIDTime("short")
+		Expect(err).To(HaveOccurred())
+
+		_, err = PushIDTime("-KZ!abcdefghijklmnop")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("PushIdempotent", func() {
+		var (
+			testServer *httptest.Server
+			testClient *client
+			paths      []string
+		)
+
+		BeforeEach(func() {
+			paths = nil
+			testServer, testClient = fakeServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal("PUT"))
+				io.ReadAll(r.Body)
+				paths = append(paths, r.URL.Path)
+
+				// Fail the first attempt, after the write happened.
+				if len(paths) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			testServer.Close()
+		})
+
+		It("Retries the write at the same generated key", func() {
+			ref, err := testClient.Child("a").PushIdempotent(Widget{A: 1}, nil, Silent())
+			Expect(err).To(BeNil())
+
+			_, err = PushIDTime(ref.Key())
+			Expect(err).To(BeNil())
+
+			expected := "/a/" + ref.Key() + ".json"
+			Expect(paths).To(Equal([]string{expected, expected}))
+		})
+	})
+})
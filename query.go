@@ -0,0 +1,350 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// InvalidQueryError is returned by a Query's Value and Watch methods when the
+// query was built with a combination of parameters that Firebase does not
+// accept.
+type InvalidQueryError struct {
+	// Param is the query parameter that could not be added.
+	Param string
+
+	// Reason describes why the parameter was rejected.
+	Reason string
+}
+
+func (e *InvalidQueryError) Error() string {
+	return fmt.Sprintf("firebase: invalid query parameter %s: %s", e.Param,
+		e.Reason)
+}
+
+// query is the default implementation of Query. It pairs a reference to a
+// location with the params that filter reads and watches of that location.
+type query struct {
+	// ref is the queried location.
+	ref *client
+
+	// order is the ordering being enforced on this query, as given to
+	// OrderBy.
+	order string
+
+	// params are the query string parameters sent along with every read and
+	// watch.
+	params map[string]string
+
+	// start and end are the key tiebreakers of the query's range, if any.
+	// Firebase's REST API cannot express them, so they are applied to the
+	// results client-side.
+	start, end *keyBound
+
+	// err is the first problem found while building the query. It is
+	// returned by Value and Watch.
+	err error
+}
+
+// keyBound is one end of a query range that also compares keys, for children
+// whose ordered value is equal to the bound's value.
+type keyBound struct {
+	value sortValue
+	key   string
+
+	// exclusive is set when a child equal to the bound (in both value and
+	// key) is outside of the range.
+	exclusive bool
+}
+
+// query returns an empty query of the client's location.
+func (c *client) query() *query {
+	return &query{ref: c}
+}
+
+func (q *query) String() string {
+	return redactURL(q.ref.url)
+}
+
+func (q *query) Ref() Reference {
+	return q.ref.withPath(q.ref.path)
+}
+
+func (q *query) Value(destination interface{}) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	if q.start == nil && q.end == nil {
+		return q.ref.value(q.params, destination)
+	}
+
+	// The key tiebreakers have to be applied to the results before they are
+	// decoded into the destination.
+	children, err := q.Children()
+	if err != nil {
+		return err
+	}
+
+	var object bytes.Buffer
+	object.WriteByte('{')
+	for i, child := range children {
+		if i > 0 {
+			object.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(child.Key)
+		object.Write(key)
+		object.WriteByte(':')
+		object.Write(child.Value)
+	}
+	object.WriteByte('}')
+
+	return q.ref.codec.Unmarshal(object.Bytes(), destination)
+}
+
+func (q *query) Watch(unmarshaller EventUnmarshaller, stop <-chan bool) (<-chan StreamEvent, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	return q.ref.watch(q.params, unmarshaller, stop)
+}
+
+// copy returns a shallow copy of the query. The params map is shared, so it
+// must be replaced rather than modified.
+func (q *query) copy() *query {
+	newQ := *q
+	return &newQ
+}
+
+// These are some shenanigans, golang. Shenanigans I say.
+func (q *query) withParam(param string, value interface{}) *query {
+	params := make(map[string]string, len(q.params)+1)
+	for key, value := range q.params {
+		params[key] = value
+	}
+	jsonVal, _ := json.Marshal(value)
+	params[param] = string(jsonVal)
+
+	newQ := q.copy()
+	newQ.params = params
+	return newQ
+}
+
+// invalid returns a copy of the query that fails with an *InvalidQueryError,
+// unless the query has already failed.
+func (q *query) invalid(param, reason string) *query {
+	newQ := q.copy()
+
+	if newQ.err == nil {
+		newQ.err = &InvalidQueryError{Param: param, Reason: reason}
+	}
+
+	return newQ
+}
+
+// has reports whether any of the given params are already set on the query.
+func (q *query) has(params ...string) bool {
+	for _, param := range params {
+		if _, ok := q.params[param]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rangeFilter adds a range filter param to the query. Range filters need an
+// ordering, may only be set once, and cannot be combined with any of the
+// conflicting params. Their value must encode to a JSON null, boolean, number
+// or string; when ordering by key, it must be a string.
+func (q *query) rangeFilter(param string, value interface{}, conflicts ...string) *query {
+	switch {
+	case q.order == "":
+		return q.invalid(param, "orderBy must be set before a range filter")
+	case q.has(param):
+		return q.invalid(param, "already set")
+	case q.has(conflicts...):
+		return q.invalid(param, fmt.Sprintf("cannot be combined with %v",
+			conflicts))
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return q.invalid(param, "value is not JSON-encodable: "+err.Error())
+	}
+
+	switch {
+	case encoded[0] == '{' || encoded[0] == '[':
+		return q.invalid(param,
+			"value must be null, a boolean, a number or a string")
+	case q.order == KeyProp && encoded[0] != '"':
+		return q.invalid(param, "value must be a string when ordering by key")
+	}
+
+	return q.withParam(param, value)
+}
+
+// keyRangeFilter adds a range filter that breaks ties between children with
+// the same value by comparing their keys. The value is sent to Firebase as
+// param, and the key comparison is done client-side.
+func (q *query) keyRangeFilter(param string, value interface{}, key string, exclusive bool, conflicts ...string) *query {
+	if q.order == KeyProp {
+		return q.invalid(param,
+			"a key tiebreaker cannot be used when ordering by key")
+	}
+
+	if reason := keyProblem(key); reason != "" {
+		return q.invalid(param, reason)
+	}
+
+	newQ := q.rangeFilter(param, value, conflicts...)
+	if newQ.err != nil {
+		return newQ
+	}
+
+	// Decode the value the same way children are decoded, so that they can
+	// be compared.
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(newQ.params[param]))
+	decoder.UseNumber()
+	decoder.Decode(&decoded)
+
+	bound := &keyBound{
+		value:     newSortValue(decoded),
+		key:       key,
+		exclusive: exclusive,
+	}
+
+	if param == "startAt" {
+		newQ.start = bound
+	} else {
+		newQ.end = bound
+	}
+
+	return newQ
+}
+
+// inRange reports whether a child with the given key and ordered value is
+// within the query's key tiebreakers.
+func (q *query) inRange(key string, value sortValue) bool {
+	if q.start != nil {
+		cmp := compareValues(value, q.start.value)
+		if cmp == 0 {
+			cmp = compareKeys(key, q.start.key)
+		}
+
+		if cmp < 0 || (cmp == 0 && q.start.exclusive) {
+			return false
+		}
+	}
+
+	if q.end != nil {
+		cmp := compareValues(value, q.end.value)
+		if cmp == 0 {
+			cmp = compareKeys(key, q.end.key)
+		}
+
+		if cmp > 0 || (cmp == 0 && q.end.exclusive) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// limit adds a limit param to the query. Only one limit may be set.
+func (q *query) limit(param string, limit uint) *query {
+	if q.has("limitToFirst", "limitToLast") {
+		return q.invalid(param, "only one limit may be set")
+	}
+
+	return q.withParam(param, limit)
+}
+
+// Query functions. They map directly to the Firebase operations.
+// https://www.firebase.com/docs/rest/guide/retrieving-data.html#section-rest-queries
+func (q *query) OrderBy(prop string) Query {
+	if q.has("orderBy") {
+		return q.invalid("orderBy", "already set")
+	}
+
+	newQ := q.withParam("orderBy", prop)
+	newQ.order = prop
+	return newQ
+}
+
+func (q *query) OrderByKey() Query {
+	return q.OrderBy(KeyProp)
+}
+
+func (q *query) OrderByValue() Query {
+	return q.OrderBy(ValueProp)
+}
+
+func (q *query) OrderByPriority() Query {
+	return q.OrderBy(PriorityProp)
+}
+
+func (q *query) OrderByChild(path string) Query {
+	childPath, err := ParsePath(path)
+	if err != nil {
+		return q.invalid("orderBy", err.Error())
+	}
+
+	if len(childPath) == 0 {
+		return q.invalid("orderBy", "empty child path")
+	}
+
+	return q.OrderBy(childPath.String())
+}
+
+func (q *query) EqualTo(value interface{}) Query {
+	return q.rangeFilter("equalTo", value, "startAt", "startAfter", "endAt",
+		"endBefore")
+}
+
+func (q *query) StartAt(value interface{}) Query {
+	return q.rangeFilter("startAt", value, "equalTo", "startAfter")
+}
+
+func (q *query) StartAfter(value interface{}) Query {
+	return q.rangeFilter("startAfter", value, "equalTo", "startAt")
+}
+
+func (q *query) EndAt(value interface{}) Query {
+	return q.rangeFilter("endAt", value, "equalTo", "endBefore")
+}
+
+func (q *query) EndBefore(value interface{}) Query {
+	return q.rangeFilter("endBefore", value, "equalTo", "endAt")
+}
+
+func (q *query) StartAtKey(value interface{}, key string) Query {
+	return q.keyRangeFilter("startAt", value, key, false, "equalTo",
+		"startAfter")
+}
+
+func (q *query) StartAfterKey(value interface{}, key string) Query {
+	return q.keyRangeFilter("startAt", value, key, true, "equalTo",
+		"startAfter")
+}
+
+func (q *query) EndAtKey(value interface{}, key string) Query {
+	return q.keyRangeFilter("endAt", value, key, false, "equalTo",
+		"endBefore")
+}
+
+func (q *query) EndBeforeKey(value interface{}, key string) Query {
+	return q.keyRangeFilter("endAt", value, key, true, "equalTo", "endBefore")
+}
+
+func (q *query) LimitToFirst(limit uint) Query {
+	return q.limit("limitToFirst", limit)
+}
+
+func (q *query) LimitToLast(limit uint) Query {
+	return q.limit("limitToLast", limit)
+}
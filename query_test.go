@@ -0,0 +1,180 @@
+package firebase
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Building queries", func() {
+	var c *client
+
+	BeforeEach(func() {
+		var isClient bool
+		c, isClient = NewClient("https://who.cares.com", testAuth, nil).(*client)
+		Expect(isClient).To(BeTrue())
+	})
+
+	expectInvalid := func(q Query, param string) {
+		invalid, isQuery := q.(*query)
+		Expect(isQuery).To(BeTrue())
+		Expect(invalid.err).To(BeAssignableToTypeOf(&InvalidQueryError{}))
+		Expect(invalid.err.(*InvalidQueryError).Param).To(Equal(param))
+	}
+
+	It("Requires orderBy before a range filter", func() {
+		expectInvalid(c.StartAt(1), "startAt")
+		expectInvalid(c.EndAt(1), "endAt")
+		expectInvalid(c.LimitToFirst(1).EqualTo(1), "equalTo")
+	})
+
+	It("Does not combine equalTo with startAt or endAt", func() {
+		expectInvalid(c.OrderBy("a").StartAt(1).EqualTo(1), "equalTo")
+		expectInvalid(c.OrderBy("a").EqualTo(1).EndAt(1), "endAt")
+	})
+
+	It("Allows only one limit", func() {
+		expectInvalid(c.LimitToFirst(1).LimitToLast(1), "limitToLast")
+		expectInvalid(c.OrderBy("a").LimitToLast(1).LimitToLast(2), "limitToLast")
+	})
+
+	It("Allows orderBy only once", func() {
+		expectInvalid(c.OrderBy("a").OrderBy("b"), "orderBy")
+	})
+
+	It("Rejects values that are not JSON primitives", func() {
+		expectInvalid(c.OrderByValue().StartAt(make(chan int)), "startAt")
+		expectInvalid(c.OrderByValue().EndBefore([]int{1}), "endBefore")
+		expectInvalid(c.OrderByValue().EqualTo(map[string]int{}), "equalTo")
+		expectInvalid(c.OrderByKey().StartAfter(1), "startAfter")
+	})
+
+	It("Does not combine exclusive and inclusive bounds", func() {
+		expectInvalid(c.OrderByValue().StartAt(1).StartAfter(1), "startAfter")
+		expectInvalid(c.OrderByValue().EndBefore(1).EndAt(1), "endAt")
+		expectInvalid(c.OrderByValue().StartAfter(1).EqualTo(1), "equalTo")
+	})
+
+	It("Does not allow key tiebreakers when ordering by key", func() {
+		expectInvalid(c.OrderByKey().StartAtKey("a", "b"), "startAt")
+		expectInvalid(c.OrderByValue().StartAtKey(1, "a.b"), "startAt")
+	})
+
+	It("Orders by the special properties and child paths", func() {
+		for prop, q := range map[string]Query{
+			`"$key"`:      c.OrderByKey(),
+			`"$value"`:    c.OrderByValue(),
+			`"$priority"`: c.OrderByPriority(),
+			`"a/b"`:       c.OrderByChild("/a/b"),
+		} {
+			ordered, isQuery := q.(*query)
+			Expect(isQuery).To(BeTrue())
+			Expect(ordered.err).To(BeNil())
+			Expect(ordered.params["orderBy"]).To(Equal(prop))
+		}
+
+		expectInvalid(c.OrderByChild("a/$b"), "orderBy")
+		expectInvalid(c.OrderByChild("/"), "orderBy")
+	})
+
+	It("Sends exclusive bounds as startAfter and endBefore", func() {
+		ranged, isQuery := c.OrderByKey().StartAfter("a").EndBefore("c").(*query)
+		Expect(isQuery).To(BeTrue())
+		Expect(ranged.params).To(Equal(map[string]string{
+			"orderBy":    `"$key"`,
+			"startAfter": `"a"`,
+			"endBefore":  `"c"`,
+		}))
+	})
+
+	It("Keeps the first error as the query grows", func() {
+		expectInvalid(c.StartAt(1).OrderBy("a").EqualTo(1), "startAt")
+	})
+})
+
+var _ = Describe("Reading queries", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		requests   int
+	)
+
+	BeforeEach(func() {
+		requests = 0
+		testServer, testClient = fakeServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				Expect(r.Method).To(Equal("GET"))
+				Expect(r.URL.Query().Get("orderBy")).To(Equal(`"$key"`))
+				Expect(r.URL.Query().Get("limitToFirst")).To(Equal("2"))
+				fmt.Fprintln(w, `{"a": 1, "b": 2}`)
+			}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("Sends the query params along with the read", func() {
+		var r map[string]int
+		err := testClient.Child("scores").OrderBy(KeyProp).LimitToFirst(2).Value(&r)
+		Expect(err).To(BeNil())
+		Expect(r).To(Equal(map[string]int{"a": 1, "b": 2}))
+	})
+
+	It("Fails an invalid query before making any request", func() {
+		var r map[string]int
+		err := testClient.Child("scores").StartAt(2).Value(&r)
+		Expect(err).To(BeAssignableToTypeOf(&InvalidQueryError{}))
+
+		_, err = testClient.Child("scores").StartAt(2).Watch(nil, nil)
+		Expect(err).To(BeAssignableToTypeOf(&InvalidQueryError{}))
+
+		Expect(requests).To(Equal(0))
+	})
+})
+
+var _ = Describe("Reading queries with key tiebreakers", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+	)
+
+	BeforeEach(func() {
+		testServer, testClient = fakeServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("startAt")).To(Equal("10"))
+				Expect(r.URL.Query().Get("endAt")).To(Equal("20"))
+				fmt.Fprintln(w, `{"a": 10, "b": 10, "c": 10, "d": 15,
+					"e": 20, "f": 20}`)
+			}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("Filters children equal to the bounds by key", func() {
+		scores := testClient.OrderByValue().StartAfterKey(10, "a").EndAtKey(20, "e")
+
+		children, err := scores.Children()
+		Expect(err).To(BeNil())
+		Expect(keysOf(children)).To(Equal([]string{"b", "c", "d", "e"}))
+
+		var r map[string]int
+		Expect(scores.Value(&r)).To(Succeed())
+		Expect(r).To(Equal(map[string]int{"b": 10, "c": 10, "d": 15, "e": 20}))
+	})
+
+	It("Includes the bounds themselves unless they are exclusive", func() {
+		scores := testClient.OrderByValue().StartAtKey(10, "b").EndBeforeKey(20, "f")
+
+		children, err := scores.Children()
+		Expect(err).To(BeNil())
+		Expect(keysOf(children)).To(Equal([]string{"b", "c", "d", "e"}))
+	})
+})
@@ -0,0 +1,79 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// readerDestination is a Call destination that takes over the response body
+// instead of having it decoded. The default Api implementation checks for it.
+type readerDestination interface {
+	takeBody(body io.ReadCloser)
+}
+
+// rawBody is the destination used by ValueReader.
+type rawBody struct {
+	body io.ReadCloser
+}
+
+func (r *rawBody) takeBody(body io.ReadCloser) {
+	r.body = body
+}
+
+// UnmarshalJSON lets Api implementations that don't know about reader
+// destinations decode into a rawBody, from a copy of the response body.
+func (r *rawBody) UnmarshalJSON(b []byte) error {
+	r.body = io.NopCloser(bytes.NewReader(append([]byte(nil), b...)))
+	return nil
+}
+
+// valueRaw GETs the value at the client's location, filtered by params,
+// without decoding it.
+func (c *client) valueRaw(params map[string]string) (json.RawMessage, error) {
+	var raw json.RawMessage
+	if err := c.value(params, &raw); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// valueReader GETs the value at the client's location, filtered by params,
+// and returns the response body for the caller to read.
+func (c *client) valueReader(params map[string]string) (io.ReadCloser, error) {
+	reader := &rawBody{}
+	if err := c.value(params, reader); err != nil {
+		return nil, err
+	}
+
+	if reader.body == nil {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	return reader.body, nil
+}
+
+func (c *client) ValueRaw() (json.RawMessage, error) {
+	return c.valueRaw(nil)
+}
+
+func (c *client) ValueReader() (io.ReadCloser, error) {
+	return c.valueReader(nil)
+}
+
+func (q *query) ValueRaw() (json.RawMessage, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	return q.ref.valueRaw(q.params)
+}
+
+func (q *query) ValueReader() (io.ReadCloser, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	return q.ref.valueReader(q.params)
+}
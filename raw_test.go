@@ -0,0 +1,96 @@
+package firebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Raw values", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		handler    func(w http.ResponseWriter, r *http.Request)
+	)
+
+	JustBeforeEach(func() {
+		testServer, testClient = fakeServer(http.HandlerFunc(handler))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	Context("Reading", func() {
+		BeforeEach(func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"b":  2, "a":1.50}`)
+			}
+		})
+
+		It("Returns the bytes sent by Firebase", func() {
+			raw, err := testClient.ValueRaw()
+			Expect(err).To(BeNil())
+			Expect(string(raw)).To(Equal(`{"b":  2, "a":1.50}`))
+		})
+
+		It("Returns the response body as a reader", func() {
+			reader, err := testClient.OrderByKey().LimitToFirst(2).ValueReader()
+			Expect(err).To(BeNil())
+			defer reader.Close()
+
+			body, err := io.ReadAll(reader)
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(Equal(`{"b":  2, "a":1.50}`))
+		})
+	})
+
+	Context("Writing", func() {
+		var (
+			bodies   []string
+			failures int
+		)
+
+		BeforeEach(func() {
+			bodies = nil
+			failures = 1
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				Expect(err).To(BeNil())
+				bodies = append(bodies, string(body))
+
+				if failures > 0 {
+					failures--
+					w.WriteHeader(http.StatusInternalServerError)
+					fmt.Fprint(w, `{"error": "try again"}`)
+				}
+			}
+		})
+
+		It("Sends a json.RawMessage without re-marshalling it", func() {
+			_, err := testClient.Set("raw", json.RawMessage(`{"b":  2, "a":1.50}`), nil)
+			Expect(err).To(BeNil())
+			Expect(bodies).To(Equal([]string{
+				`{"b":  2, "a":1.50}`, `{"b":  2, "a":1.50}`}))
+		})
+
+		It("Sends a seekable reader again when retrying", func() {
+			err := testClient.Update("raw", strings.NewReader(`{"a": 1}`), nil)
+			Expect(err).To(BeNil())
+			Expect(bodies).To(Equal([]string{`{"a": 1}`, `{"a": 1}`}))
+		})
+
+		It("Does not retry a reader that cannot seek", func() {
+			reader := io.MultiReader(strings.NewReader(`{"a": 1}`))
+			err := testClient.Update("raw", reader, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(bodies).To(Equal([]string{`{"a": 1}`}))
+		})
+	})
+})
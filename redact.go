@@ -0,0 +1,41 @@
+package firebase
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// redactedValue replaces credentials in redacted URLs.
+const redactedValue = "REDACTED"
+
+// credentialParam matches the query string params that hold credentials.
+var credentialParam = regexp.MustCompile(`(^|[?&])(auth|access_token)=[^&#]*`)
+
+// redactURL returns rawURL with its credentials replaced: the auth and
+// access_token query string params, and the password of its user info. URLs
+// that can't be parsed have their params redacted all the same.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return credentialParam.ReplaceAllString(rawURL, "${1}${2}="+redactedValue)
+	}
+
+	u.RawQuery = credentialParam.ReplaceAllString(u.RawQuery, "${1}${2}="+redactedValue)
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+
+	return u.String()
+}
+
+// redactError returns err with the credentials of its URL redacted, if it is
+// a *url.Error, as returned by http.Client and url.Parse. The redacted error
+// is still a *url.Error, wrapping the same error.
+func redactError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+
+	return &url.Error{Op: urlErr.Op, URL: redactURL(urlErr.URL), Err: urlErr.Err}
+}
@@ -0,0 +1,170 @@
+package firebase
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// ServerValue is a placeholder that Firebase replaces with a value computed on
+// its servers when it is written. See ServerTime and Increment.
+//
+// https://firebase.google.com/docs/reference/rest/database#section-server-values
+type ServerValue struct {
+	sv interface{}
+}
+
+func (v ServerValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{".sv": v.sv})
+}
+
+// ServerTime is replaced by Firebase with its current time, in milliseconds
+// since the epoch:
+//
+//	{".sv":"timestamp"}
+var ServerTime = ServerValue{sv: "timestamp"}
+
+// Increment is replaced by Firebase with the sum of the value currently stored
+// at its location and delta. A missing or non-numeric value counts as 0, so
+// concurrent increments never overwrite each other:
+//
+//	{".sv":{"increment":delta}}
+func Increment(delta int64) ServerValue {
+	return ServerValue{sv: map[string]int64{"increment": delta}}
+}
+
+// IncrementFloat is like Increment, for a fractional delta.
+func IncrementFloat(delta float64) ServerValue {
+	return ServerValue{sv: map[string]float64{"increment": delta}}
+}
+
+// isServerValue reports whether b is the JSON of a server value placeholder,
+// such as a timestamp that has been written locally but not yet resolved by
+// Firebase.
+func isServerValue(b []byte) bool {
+	if len(b) == 0 || b[0] != '{' {
+		return false
+	}
+
+	var placeholder map[string]json.RawMessage
+	if err := json.Unmarshal(b, &placeholder); err != nil {
+		return false
+	}
+
+	_, ok := placeholder[".sv"]
+	return ok
+}
+
+// parseMillis decodes a JSON number of milliseconds since the epoch, in any
+// number format, so that it can be decoded by codecs that re-encode numbers
+// (e.g. as 1.7e+12). A null or a server value placeholder decodes to the zero
+// time.
+func parseMillis(b []byte) (time.Time, error) {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" || isServerValue(b) {
+		return time.Time{}, nil
+	}
+
+	ms, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		floatMs, floatErr := strconv.ParseFloat(string(b), 64)
+		if floatErr != nil {
+			return time.Time{}, err
+		}
+		ms = int64(floatMs)
+	}
+
+	// Firebase reports milliseconds since the epoch, Go counts in ns.
+	return time.UnixMilli(ms), nil
+}
+
+// ServerTimestamp is a Go binding for Firebase's ServerValue.TIMESTAMP fields.
+// When marshalling a variable of ServerTimestamp type into JSON (i.e. to send
+// to Firebase), it takes the following JSON representation, no matter what
+// time value the variable has been assigned:
+//
+//	{".sv":"timestamp"}
+//
+// When this JSON value is sent to Firebase, it is substituted into a number
+// equal to milliseconds since the epoch, as measured by Firebase's servers.
+//
+// When reading a value of this type from Firebase, you receive a number equal
+// to milliseconds since the epoch. That value was computed by Firebase when
+// the JSON detailed above was written. A JSON unmarshal of this type will
+// convert a number of ms since the epoch into a time.Time value. Unmarshalling
+// the placeholder itself yields the zero time.
+//
+// NOTE: This approach results in non-symmetric marshal/unmarshal behavior
+// (i.e. unmarshal(marshal(ServerTimestamp(t))) loses t). Use Timestamp for
+// values that also have to round-trip through JSON, e.g. in a local cache.
+//
+// See the Firebase's documentation of ServerValues and timestamps for more
+// details:
+// https://www.firebase.com/docs/rest/api/#section-server-values
+type ServerTimestamp time.Time
+
+func (t ServerTimestamp) MarshalJSON() ([]byte, error) {
+	return ServerTime.MarshalJSON()
+}
+
+func (t *ServerTimestamp) UnmarshalJSON(b []byte) error {
+	parsed, err := parseMillis(b)
+	if err != nil {
+		return err
+	}
+
+	*t = ServerTimestamp(parsed)
+	return nil
+}
+
+// Timestamp is a time that Firebase fills in with its server time when it is
+// written unset. A zero Timestamp marshals to the {".sv":"timestamp"}
+// placeholder, and any other Timestamp marshals to its milliseconds since
+// the epoch. Unmarshalling reverses both, so a Timestamp survives a round
+// trip through JSON: a resolved time stays the same, and a pending
+// placeholder stays pending.
+type Timestamp time.Time
+
+// IsPending reports whether the timestamp is still waiting for Firebase to
+// fill it in.
+func (t Timestamp) IsPending() bool {
+	return time.Time(t).IsZero()
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsPending() {
+		return ServerTime.MarshalJSON()
+	}
+
+	return MillisTime(t).MarshalJSON()
+}
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	parsed, err := parseMillis(b)
+	if err != nil {
+		return err
+	}
+
+	*t = Timestamp(parsed)
+	return nil
+}
+
+// MillisTime is a time stored as a number of milliseconds since the epoch,
+// the way Firebase stores its timestamps. It marshals to that number and
+// unmarshals from it, with no server value involved.
+type MillisTime time.Time
+
+func (t MillisTime) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(time.Time(t).UnixMilli(), 10)), nil
+}
+
+func (t *MillisTime) UnmarshalJSON(b []byte) error {
+	parsed, err := parseMillis(b)
+	if err != nil {
+		return err
+	}
+
+	*t = MillisTime(parsed)
+	return nil
+}
@@ -0,0 +1,68 @@
+package firebase
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Firebase server values", func() {
+	It("Marshals increments", func() {
+		text, err := json.Marshal(map[string]interface{}{
+			"count": Increment(-2),
+			"total": IncrementFloat(0.5),
+			"at":    ServerTime,
+		})
+		Expect(err).To(BeNil())
+		Expect(string(text)).To(Equal(`{"at":{".sv":"timestamp"},` +
+			`"count":{".sv":{"increment":-2}},"total":{".sv":{"increment":0.5}}}`))
+	})
+
+	It("Unmarshals a pending placeholder into a zero ServerTimestamp", func() {
+		ts := ServerTimestamp(time.Now())
+		Expect(json.Unmarshal([]byte(`{".sv":"timestamp"}`), &ts)).To(Succeed())
+		Expect(time.Time(ts).IsZero()).To(BeTrue())
+	})
+
+	It("Round-trips a Timestamp, pending or resolved", func() {
+		var pending Timestamp
+		text, err := json.Marshal(pending)
+		Expect(err).To(BeNil())
+		Expect(string(text)).To(Equal(`{".sv":"timestamp"}`))
+
+		var decoded Timestamp
+		Expect(json.Unmarshal(text, &decoded)).To(Succeed())
+		Expect(decoded.IsPending()).To(BeTrue())
+
+		resolved := Timestamp(time.UnixMilli(1700000000123))
+		text, err = json.Marshal(resolved)
+		Expect(err).To(BeNil())
+		Expect(string(text)).To(Equal("1700000000123"))
+
+		Expect(json.Unmarshal(text, &decoded)).To(Succeed())
+		Expect(decoded.IsPending()).To(BeFalse())
+		Expect(time.Time(decoded).Equal(time.Time(resolved))).To(BeTrue())
+	})
+
+	It("Round-trips a MillisTime", func() {
+		type Record struct {
+			Created MillisTime `json:"created"`
+		}
+
+		record := Record{Created: MillisTime(time.UnixMilli(1700000000123))}
+		text, err := json.Marshal(record)
+		Expect(err).To(BeNil())
+		Expect(string(text)).To(Equal(`{"created":1700000000123}`))
+
+		var decoded Record
+		Expect(json.Unmarshal(text, &decoded)).To(Succeed())
+		Expect(time.Time(decoded.Created).Equal(time.Time(record.Created))).To(BeTrue())
+	})
+
+	It("Rejects values that are not numbers", func() {
+		var t MillisTime
+		Expect(json.Unmarshal([]byte(`"yesterday"`), &t)).NotTo(Succeed())
+	})
+})
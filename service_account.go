@@ -0,0 +1,214 @@
+package firebase
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTokenEndpoint is Google's OAuth2 token endpoint, where service
+// account assertions are exchanged for access tokens.
+const DefaultTokenEndpoint = "https://oauth2.googleapis.com/token"
+
+// serviceAccountScopes are the scopes of the access tokens of a service
+// account: admin access to the database, and the account's identity.
+var serviceAccountScopes = []string{
+	"https://www.googleapis.com/auth/firebase.database",
+	"https://www.googleapis.com/auth/userinfo.email",
+}
+
+// assertionLifetime is how long a service account assertion is valid. Google
+// accepts assertions of up to an hour.
+const assertionLifetime = time.Hour
+
+// jwtBearerGrant is the OAuth2 grant type of JWT assertions.
+const jwtBearerGrant = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// errNoClientEmail is returned for service account keys without an email.
+var errNoClientEmail = errors.New("firebase: service account key has no client_email")
+
+// ServiceAccountKey is a service account's JSON key file, as downloaded from
+// the Firebase or Google Cloud console.
+type ServiceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// ServiceAccountOption configures a ServiceAccountTokenSource.
+type ServiceAccountOption func(*ServiceAccountTokenSource)
+
+// TokenEndpoint makes the token source exchange its assertions at endpoint,
+// instead of the token_uri of the key (or DefaultTokenEndpoint, if the key
+// has none).
+func TokenEndpoint(endpoint string) ServiceAccountOption {
+	return func(s *ServiceAccountTokenSource) {
+		s.endpoint = endpoint
+	}
+}
+
+// TokenHTTPClient makes the token source reach the token endpoint with
+// client, instead of the client of regular calls.
+func TokenHTTPClient(client *http.Client) ServiceAccountOption {
+	return func(s *ServiceAccountTokenSource) {
+		s.client = client
+	}
+}
+
+// ServiceAccountTokenSource returns Google OAuth2 access tokens of a service
+// account, which have admin access to the database. Its tokens are access
+// tokens, so the client must send them as such:
+//
+//	client := firebase.NewClient(url, "", nil,
+//		firebase.WithTokenSource(source), firebase.WithAuthMode(firebase.AuthBearer))
+//
+// Every call to Token gets a new access token; WithTokenSource caches them.
+type ServiceAccountTokenSource struct {
+	key        ServiceAccountKey
+	privateKey *rsa.PrivateKey
+	endpoint   string
+	client     *http.Client
+}
+
+// NewServiceAccountTokenSource returns a token source of the service account
+// whose JSON key is keyJSON.
+func NewServiceAccountTokenSource(keyJSON []byte, options ...ServiceAccountOption) (*ServiceAccountTokenSource, error) {
+	var key ServiceAccountKey
+	if err := json.Unmarshal(keyJSON, &key); err != nil {
+		return nil, fmt.Errorf("firebase: parsing service account key: %w", err)
+	}
+	if key.ClientEmail == "" {
+		return nil, errNoClientEmail
+	}
+
+	privateKey, err := parsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ServiceAccountTokenSource{
+		key:        key,
+		privateKey: privateKey,
+		endpoint:   key.TokenURI,
+	}
+	if s.endpoint == "" {
+		s.endpoint = DefaultTokenEndpoint
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// ServiceAccountTokenSourceFromFile returns a token source of the service
+// account whose JSON key file is at path.
+func ServiceAccountTokenSourceFromFile(path string, options ...ServiceAccountOption) (*ServiceAccountTokenSource, error) {
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewServiceAccountTokenSource(keyJSON, options...)
+}
+
+// Key returns the service account's key.
+func (s *ServiceAccountTokenSource) Key() ServiceAccountKey {
+	return s.key
+}
+
+// assertionClaims are the claims of a service account's assertion.
+type assertionClaims struct {
+	Issuer   string `json:"iss"`
+	Scope    string `json:"scope"`
+	Audience string `json:"aud"`
+	IssuedAt int64  `json:"iat"`
+	Expiry   int64  `json:"exp"`
+}
+
+// tokenResponse is the token endpoint's answer.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// TokenEndpointError is returned when the token endpoint refuses to issue
+// an access token.
+type TokenEndpointError struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *TokenEndpointError) Error() string {
+	return fmt.Sprintf("firebase: token endpoint answered %d: %s: %s",
+		e.StatusCode, e.Code, e.Description)
+}
+
+// Token signs an assertion of the service account, and exchanges it for an
+// access token at the token endpoint.
+func (s *ServiceAccountTokenSource) Token() (*Token, error) {
+	now := time.Now()
+	assertion, err := signRS256(assertionClaims{
+		Issuer:   s.key.ClientEmail,
+		Scope:    strings.Join(serviceAccountScopes, " "),
+		Audience: s.endpoint,
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(assertionLifetime).Unix(),
+	}, s.privateKey, s.key.PrivateKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s.client
+	if client == nil {
+		client = httpClient
+	}
+
+	response, err := client.PostForm(s.endpoint, url.Values{
+		"grant_type": {jwtBearerGrant},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(response.Body)
+
+	var answer tokenResponse
+	decodeErr := json.NewDecoder(response.Body).Decode(&answer)
+
+	if response.StatusCode != http.StatusOK {
+		return nil, &TokenEndpointError{
+			StatusCode:  response.StatusCode,
+			Code:        answer.Error,
+			Description: answer.ErrorDescription,
+		}
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("firebase: decoding access token: %w", decodeErr)
+	}
+	if answer.AccessToken == "" {
+		return nil, errEmptyToken
+	}
+
+	token := &Token{Value: answer.AccessToken}
+	if answer.ExpiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(answer.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,181 @@
+package firebase
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testServiceAccountKey returns a service account JSON key with a new RSA
+// key, in PKCS #8 form like Google's.
+func testServiceAccountKey(tokenURI string) ([]byte, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).To(BeNil())
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	Expect(err).To(BeNil())
+
+	keyJSON, err := json.Marshal(ServiceAccountKey{
+		Type:         "service_account",
+		ProjectID:    "dinosaur-facts",
+		PrivateKeyID: "key-1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		ClientEmail:  "admin@dinosaur-facts.iam.gserviceaccount.com",
+		TokenURI:     tokenURI,
+	})
+	Expect(err).To(BeNil())
+
+	return keyJSON, privateKey
+}
+
+// verifyRS256 checks the signature of a JWT, and returns its header and
+// claims.
+func verifyRS256(token string, key *rsa.PublicKey) (header, claims map[string]interface{}) {
+	parts := strings.Split(token, ".")
+	Expect(parts).To(HaveLen(3))
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	Expect(err).To(BeNil())
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	Expect(rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)).To(Succeed())
+
+	decode := func(part string) map[string]interface{} {
+		data, err := base64.RawURLEncoding.DecodeString(part)
+		Expect(err).To(BeNil())
+
+		var decoded map[string]interface{}
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		return decoded
+	}
+
+	return decode(parts[0]), decode(parts[1])
+}
+
+var _ = Describe("Service account token source", func() {
+	var (
+		endpoint   *httptest.Server
+		privateKey *rsa.PrivateKey
+		keyJSON    []byte
+		answer     func(w http.ResponseWriter)
+	)
+
+	BeforeEach(func() {
+		answer = func(w http.ResponseWriter) {
+			fmt.Fprint(w, `{"access_token":"ya29.access","expires_in":3600,"token_type":"Bearer"}`)
+		}
+
+		endpoint = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+
+			Expect(r.Method).To(Equal("POST"))
+			Expect(r.ParseForm()).To(Succeed())
+			Expect(r.PostForm.Get("grant_type")).To(Equal("urn:ietf:params:oauth:grant-type:jwt-bearer"))
+
+			header, claims := verifyRS256(r.PostForm.Get("assertion"), &privateKey.PublicKey)
+			Expect(header).To(Equal(map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": "key-1"}))
+			Expect(claims["iss"]).To(Equal("admin@dinosaur-facts.iam.gserviceaccount.com"))
+			Expect(claims["aud"]).To(Equal("http://" + r.Host + "/token"))
+			Expect(claims["scope"]).To(Equal("https://www.googleapis.com/auth/firebase.database " +
+				"https://www.googleapis.com/auth/userinfo.email"))
+			Expect(claims["exp"].(float64) - claims["iat"].(float64)).To(BeEquivalentTo(3600))
+
+			answer(w)
+		}))
+
+		keyJSON, privateKey = testServiceAccountKey(DefaultTokenEndpoint)
+	})
+
+	AfterEach(func() {
+		endpoint.Close()
+	})
+
+	It("Exchanges signed assertions for access tokens", func() {
+		source, err := NewServiceAccountTokenSource(keyJSON, TokenEndpoint(endpoint.URL+"/token"))
+		Expect(err).To(BeNil())
+		Expect(source.Key().ProjectID).To(Equal("dinosaur-facts"))
+
+		token, err := source.Token()
+		Expect(err).To(BeNil())
+		Expect(token.Value).To(Equal("ya29.access"))
+		Expect(token.Valid()).To(BeTrue())
+	})
+
+	It("Uses the token endpoint of the key by default", func() {
+		keyJSON, privateKey = testServiceAccountKey(endpoint.URL + "/token")
+
+		source, err := NewServiceAccountTokenSource(keyJSON)
+		Expect(err).To(BeNil())
+
+		token, err := source.Token()
+		Expect(err).To(BeNil())
+		Expect(token.Value).To(Equal("ya29.access"))
+	})
+
+	It("Reads key files", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "key.json")
+		Expect(os.WriteFile(path, keyJSON, 0600)).To(Succeed())
+
+		source, err := ServiceAccountTokenSourceFromFile(path, TokenEndpoint(endpoint.URL+"/token"))
+		Expect(err).To(BeNil())
+		Expect(source.Key().ClientEmail).To(Equal("admin@dinosaur-facts.iam.gserviceaccount.com"))
+	})
+
+	It("Reports the errors of the token endpoint", func() {
+		answer = func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Invalid JWT Signature."}`)
+		}
+
+		source, err := NewServiceAccountTokenSource(keyJSON, TokenEndpoint(endpoint.URL+"/token"))
+		Expect(err).To(BeNil())
+
+		_, err = source.Token()
+		Expect(err).To(Equal(&TokenEndpointError{
+			StatusCode:  http.StatusBadRequest,
+			Code:        "invalid_grant",
+			Description: "Invalid JWT Signature.",
+		}))
+	})
+
+	It("Rejects invalid keys", func() {
+		_, err := NewServiceAccountTokenSource([]byte(`{"client_email":"a@b.c","private_key":"nope"}`))
+		Expect(err).To(Equal(errNoPEM))
+
+		_, err = NewServiceAccountTokenSource([]byte(`{"private_key":"nope"}`))
+		Expect(err).To(Equal(errNoClientEmail))
+
+		_, err = NewServiceAccountTokenSource([]byte(`not json`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Authenticates a client with bearer access tokens", func() {
+		var authorization string
+		database := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			fmt.Fprint(w, `{"A":1}`)
+		}))
+		defer database.Close()
+
+		source, err := NewServiceAccountTokenSource(keyJSON, TokenEndpoint(endpoint.URL+"/token"))
+		Expect(err).To(BeNil())
+
+		c := NewClient(database.URL, "", nil, WithTokenSource(source), WithAuthMode(AuthBearer))
+		var w Widget
+		Expect(c.Value(&w)).To(Succeed())
+		Expect(authorization).To(Equal("Bearer ya29.access"))
+	})
+})
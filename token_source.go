@@ -0,0 +1,133 @@
+package firebase
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tokenExpiryDelta is how long before it expires a cached token is refreshed,
+// so that it doesn't expire on its way to Firebase.
+const tokenExpiryDelta = time.Minute
+
+// errEmptyToken is returned by token sources that return an empty token.
+var errEmptyToken = errors.New("firebase: token source returned an empty token")
+
+// Token is an auth token, along with when it expires.
+type Token struct {
+	// Value is the token sent to Firebase.
+	Value string
+
+	// Expiry is when the token expires. The zero time means it never does.
+	Expiry time.Time
+}
+
+// Valid tells whether the token is set, and not about to expire.
+func (t *Token) Valid() bool {
+	if t == nil || t.Value == "" {
+		return false
+	}
+
+	return t.Expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.Expiry)
+}
+
+// TokenSource returns the auth tokens of a client, like oauth2.TokenSource.
+// A client consults its token source before every call and stream, through a
+// cache (see ReuseTokenSource), so the source itself only needs to return a
+// new token when asked.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// staticTokenSource always returns the same token.
+type staticTokenSource struct {
+	token *Token
+}
+
+// StaticTokenSource returns a token source that always returns token, which
+// never expires.
+func StaticTokenSource(token string) TokenSource {
+	return staticTokenSource{token: &Token{Value: token}}
+}
+
+func (s staticTokenSource) Token() (*Token, error) {
+	return s.token, nil
+}
+
+// reuseTokenSource caches the tokens of a token source until they are about
+// to expire.
+type reuseTokenSource struct {
+	source TokenSource
+
+	// mu guards token. It is held during refreshes, so that concurrent calls
+	// wait for a single refresh instead of each making their own.
+	mu    sync.Mutex
+	token *Token
+}
+
+// ReuseTokenSource returns a token source that returns the tokens of source
+// until they are about to expire, and only then asks source for a new one.
+// It is safe for concurrent use, even if source isn't.
+func ReuseTokenSource(source TokenSource) TokenSource {
+	if reuse, ok := source.(*reuseTokenSource); ok {
+		return reuse
+	}
+
+	return &reuseTokenSource{source: source}
+}
+
+func (s *reuseTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.Value == "" {
+		return nil, errEmptyToken
+	}
+
+	s.token = token
+	return token, nil
+}
+
+// invalidate drops the cached token if it is value, which Firebase no longer
+// accepts, so that the next token is a new one.
+func (s *reuseTokenSource) invalidate(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && s.token.Value == value {
+		s.token = nil
+	}
+}
+
+// WithTokenSource makes the client get its auth token from source before
+// every call and stream, instead of using the token given to NewClient.
+// Tokens are cached until shortly before they expire. When Firebase revokes
+// the token of a Watch, a new token is fetched and the watch reconnects.
+func WithTokenSource(source TokenSource) ClientOption {
+	return func(c *client) {
+		c.tokens = ReuseTokenSource(source).(*reuseTokenSource)
+	}
+}
+
+// token returns the auth token of the client's next call or stream.
+func (c *client) token() (string, error) {
+	if c.tokens == nil {
+		return c.auth, nil
+	}
+
+	token, err := c.tokens.Token()
+	if err != nil {
+		return "", fmt.Errorf("firebase: getting auth token: %w", err)
+	}
+
+	return token.Value, nil
+}
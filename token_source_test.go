@@ -0,0 +1,249 @@
+package firebase
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingTokenSource returns the tokens "token-1", "token-2", ... which
+// expire after lifetime.
+type countingTokenSource struct {
+	lifetime time.Duration
+	calls    int32
+	err      error
+}
+
+func (s *countingTokenSource) Token() (*Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	n := atomic.AddInt32(&s.calls, 1)
+	// Give concurrent callers a chance to ask at the same time.
+	time.Sleep(10 * time.Millisecond)
+
+	return &Token{
+		Value:  fmt.Sprintf("token-%d", n),
+		Expiry: time.Now().Add(s.lifetime),
+	}, nil
+}
+
+var _ = Describe("Token sources", func() {
+	It("Knows when tokens are about to expire", func() {
+		var missing *Token
+		Expect(missing.Valid()).To(BeFalse())
+		Expect((&Token{}).Valid()).To(BeFalse())
+		Expect((&Token{Value: "a"}).Valid()).To(BeTrue())
+		Expect((&Token{Value: "a", Expiry: time.Now().Add(time.Hour)}).Valid()).To(BeTrue())
+		Expect((&Token{Value: "a", Expiry: time.Now().Add(time.Second)}).Valid()).To(BeFalse())
+	})
+
+	It("Reuses tokens until they are about to expire", func() {
+		source := &countingTokenSource{lifetime: time.Hour}
+		reuse := ReuseTokenSource(source)
+
+		for i := 0; i < 3; i++ {
+			token, err := reuse.Token()
+			Expect(err).To(BeNil())
+			Expect(token.Value).To(Equal("token-1"))
+		}
+
+		source.lifetime = time.Second
+		reuse = ReuseTokenSource(source)
+		first, _ := reuse.Token()
+		second, _ := reuse.Token()
+		Expect(first.Value).NotTo(Equal(second.Value))
+	})
+
+	It("Refreshes once for concurrent callers", func() {
+		source := &countingTokenSource{lifetime: time.Hour}
+		reuse := ReuseTokenSource(source)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+
+				token, err := reuse.Token()
+				Expect(err).To(BeNil())
+				Expect(token.Value).To(Equal("token-1"))
+			}()
+		}
+		wg.Wait()
+
+		Expect(atomic.LoadInt32(&source.calls)).To(BeEquivalentTo(1))
+	})
+
+	It("Rejects empty tokens", func() {
+		_, err := ReuseTokenSource(StaticTokenSource("")).Token()
+		Expect(err).To(Equal(errEmptyToken))
+	})
+
+	Context("Used by a client", func() {
+		var (
+			testServer *httptest.Server
+			auths      chan string
+			handler    http.HandlerFunc
+		)
+
+		BeforeEach(func() {
+			auths = make(chan string, 10)
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"A":1}`)
+			}
+			testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auths <- r.URL.Query().Get("auth")
+				handler(w, r)
+			}))
+		})
+
+		AfterEach(func() {
+			testServer.Close()
+		})
+
+		It("Gets a token for every call", func() {
+			source := &countingTokenSource{lifetime: time.Second}
+			c := NewClient(testServer.URL, "ignored", nil, WithTokenSource(source))
+
+			var w Widget
+			Expect(c.Child("a").Value(&w)).To(BeNil())
+			Expect(<-auths).To(Equal("token-1"))
+			Expect(c.Child("b").Value(&w)).To(BeNil())
+			Expect(<-auths).To(Equal("token-2"))
+		})
+
+		It("Fails calls without a token", func() {
+			c := NewClient(testServer.URL, "", nil,
+				WithTokenSource(&countingTokenSource{err: errors.New("no network")}))
+
+			var w Widget
+			err := c.Value(&w)
+			Expect(err).To(MatchError(ContainSubstring("no network")))
+			Expect(auths).NotTo(Receive())
+		})
+
+		It("Lets WithAuth override the token source", func() {
+			source := &countingTokenSource{lifetime: time.Hour}
+			c := NewClient(testServer.URL, "", nil, WithTokenSource(source))
+
+			var w Widget
+			Expect(c.WithAuth("static", AuthQuery).Value(&w)).To(BeNil())
+			Expect(<-auths).To(Equal("static"))
+			Expect(atomic.LoadInt32(&source.calls)).To(BeZero())
+		})
+
+		It("Reconnects watches with a new token when it is revoked", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				auth := r.URL.Query().Get("auth")
+				fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":{\"token\":%q}}\n\n", auth)
+				if auth == "token-1" {
+					fmt.Fprint(w, "event: auth_revoked\ndata: \"credential is no longer valid\"\n\n")
+				}
+			}
+
+			metrics := NewPrometheusCollector()
+			source := &countingTokenSource{lifetime: time.Hour}
+			c := NewClient(testServer.URL, "", nil,
+				WithTokenSource(source), WithMetrics(metrics))
+
+			stop := make(chan bool)
+			defer close(stop)
+			events, err := c.Watch(nil, stop)
+			Expect(err).To(BeNil())
+
+			var tokens []string
+			for event := range events {
+				Expect(event.Error).To(BeNil())
+				if event.Event == "put" {
+					tokens = append(tokens, event.Resource.(map[string]interface{})["token"].(string))
+				}
+			}
+			Expect(tokens).To(Equal([]string{"token-1", "token-2"}))
+			Expect(<-auths).To(Equal("token-1"))
+			Expect(<-auths).To(Equal("token-2"))
+
+			exposition := new(strings.Builder)
+			metrics.WriteTo(exposition)
+			Expect(exposition.String()).To(ContainSubstring("firebase_stream_reconnects_total 1"))
+		})
+
+		It("Ends watches whose token source returns the revoked token again", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "event: auth_revoked\ndata: \"credential is no longer valid\"\n\n")
+			}
+
+			c := NewClient(testServer.URL, "", nil, WithTokenSource(StaticTokenSource("x")))
+			stop := make(chan bool)
+			defer close(stop)
+			events, err := c.Watch(nil, stop)
+			Expect(err).To(BeNil())
+
+			event := <-events
+			Expect(event.Event).To(Equal("auth_revoked"))
+			Expect(event.RawData).To(Equal(`"credential is no longer valid"`))
+			Expect(event.Error).To(Equal(errAuthRevoked))
+			Eventually(events).Should(BeClosed())
+			Expect(auths).To(HaveLen(1))
+		})
+
+		It("Backs off between reconnects", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "event: auth_revoked\ndata: null\n\n")
+			}
+
+			source := &countingTokenSource{lifetime: time.Hour}
+			c := NewClient(testServer.URL, "", nil, WithTokenSource(source))
+			stop := make(chan bool)
+			events, err := c.Watch(nil, stop)
+			Expect(err).To(BeNil())
+
+			// Reconnects wait 100ms, then 200ms, then 400ms.
+			time.Sleep(500 * time.Millisecond)
+			close(stop)
+			Eventually(events).Should(BeClosed())
+			Expect(len(auths)).To(BeNumerically("<=", 3))
+		})
+
+		It("Ends watches whose new token can't be fetched", func() {
+			source := &failingAfterTokenSource{}
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "event: auth_revoked\ndata: null\n\n")
+			}
+
+			c := NewClient(testServer.URL, "", nil, WithTokenSource(source))
+			stop := make(chan bool)
+			defer close(stop)
+			events, err := c.Watch(nil, stop)
+			Expect(err).To(BeNil())
+
+			event := <-events
+			Expect(event.Event).To(Equal("auth_revoked"))
+			Expect(event.Error).To(MatchError(ContainSubstring("token endpoint down")))
+			Eventually(events).Should(BeClosed())
+		})
+	})
+})
+
+// failingAfterTokenSource returns a single token, and fails afterwards.
+type failingAfterTokenSource struct {
+	calls int32
+}
+
+func (s *failingAfterTokenSource) Token() (*Token, error) {
+	if atomic.AddInt32(&s.calls, 1) > 1 {
+		return nil, errors.New("token endpoint down")
+	}
+
+	return &Token{Value: "only"}, nil
+}
@@ -0,0 +1,194 @@
+package firebase
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans, to show Firebase calls in distributed traces. Its shape
+// follows OpenTelemetry's, so that an OpenTelemetry tracer can be adapted to
+// it in a few lines.
+//
+// A span is started for each call and stream of the default Api
+// implementation, as a child of the span of the client's context (see
+// WithContext), and annotated with the request's URL, method, query params,
+// status, retries and response size.
+type Tracer interface {
+	// Start starts a span named name, as a child of the span in ctx if there
+	// is one, and returns a context holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation traced by a Tracer.
+type Span interface {
+	// SetAttributes annotates the span.
+	SetAttributes(attributes ...Attribute)
+
+	// RecordError records that the operation failed with err.
+	RecordError(err error)
+
+	// End ends the span.
+	End()
+}
+
+// Attribute is an annotation of a Span. Value is a string, an int64 or a bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attributes set on the spans of calls and streams.
+const (
+	AttributeMethod       = "http.request.method"
+	AttributeURL          = "url.full"
+	AttributeStatusCode   = "http.response.status_code"
+	AttributeParams       = "firebase.params"
+	AttributeRetries      = "firebase.retries"
+	AttributeResponseSize = "firebase.response_size"
+)
+
+// WithTracer makes the default Api implementation trace its calls and streams
+// with tracer.
+func WithTracer(tracer Tracer) ClientOption {
+	return func(c *client) {
+		c.tracer = tracer
+	}
+}
+
+// nopTracer is the Tracer of clients created without one.
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
+
+// endSpan annotates the span of a request once it is done, and ends it.
+func endSpan(span Span, req *Request, err error) {
+	span.SetAttributes(
+		Attribute{Key: AttributeMethod, Value: req.Method},
+		Attribute{Key: AttributeURL, Value: req.Path},
+		Attribute{Key: AttributeParams, Value: tracedParams(req.Params)},
+		Attribute{Key: AttributeRetries, Value: int64(req.Retries)},
+		Attribute{Key: AttributeResponseSize, Value: req.ResponseSize},
+	)
+
+	if req.StatusCode != 0 {
+		span.SetAttributes(Attribute{Key: AttributeStatusCode, Value: int64(req.StatusCode)})
+	}
+
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	span.End()
+}
+
+// tracedParams encodes query params for a span, leaving out credentials.
+func tracedParams(params map[string]string) string {
+	values := url.Values{}
+	for key, value := range params {
+		if key == "auth" || key == "access_token" {
+			continue
+		}
+		values.Set(key, value)
+	}
+
+	return values.Encode()
+}
+
+// MemoryTracer is a Tracer that keeps its spans in memory, for tests.
+type MemoryTracer struct {
+	mu    sync.Mutex
+	spans []*MemorySpan
+}
+
+// MemorySpan is a span started by a MemoryTracer.
+type MemorySpan struct {
+	Name       string
+	Parent     *MemorySpan
+	Attributes map[string]interface{}
+	Errors     []error
+	Start      time.Time
+	End        time.Time
+
+	tracer *MemoryTracer
+}
+
+// memorySpanKey is the context key of the current MemorySpan.
+type memorySpanKey struct{}
+
+// NewMemoryTracer returns a MemoryTracer with no spans.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+func (t *MemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(memorySpanKey{}).(*MemorySpan)
+
+	span := &MemorySpan{
+		Name:       name,
+		Parent:     parent,
+		Attributes: map[string]interface{}{},
+		Start:      time.Now(),
+		tracer:     t,
+	}
+
+	return context.WithValue(ctx, memorySpanKey{}, span), &memorySpanHandle{span: span}
+}
+
+// Spans returns the spans that ended, in the order they ended.
+func (t *MemoryTracer) Spans() []*MemorySpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*MemorySpan(nil), t.spans...)
+}
+
+// Reset forgets the spans that ended.
+func (t *MemoryTracer) Reset() {
+	t.mu.Lock()
+	t.spans = nil
+	t.mu.Unlock()
+}
+
+// memorySpanHandle is the Span of a MemorySpan. A MemorySpan is only
+// modified through its handle, with its tracer's lock held.
+type memorySpanHandle struct {
+	span *MemorySpan
+}
+
+func (h *memorySpanHandle) SetAttributes(attributes ...Attribute) {
+	h.span.tracer.mu.Lock()
+	defer h.span.tracer.mu.Unlock()
+
+	for _, attribute := range attributes {
+		h.span.Attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (h *memorySpanHandle) RecordError(err error) {
+	h.span.tracer.mu.Lock()
+	defer h.span.tracer.mu.Unlock()
+
+	h.span.Errors = append(h.span.Errors, err)
+}
+
+func (h *memorySpanHandle) End() {
+	h.span.tracer.mu.Lock()
+	defer h.span.tracer.mu.Unlock()
+
+	if !h.span.End.IsZero() {
+		return
+	}
+
+	h.span.End = time.Now()
+	h.span.tracer.spans = append(h.span.tracer.spans, h.span)
+}
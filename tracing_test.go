@@ -0,0 +1,139 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		testServer *httptest.Server
+		tracer     *MemoryTracer
+		testClient Client
+		failures   int
+	)
+
+	BeforeEach(func() {
+		failures = 0
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if r.Header.Get("Accept") == "text/event-stream" {
+				fmt.Fprint(w, "event: keep-alive\ndata: null\n\n")
+				return
+			}
+
+			fmt.Fprint(w, `{"A":1}`)
+		}))
+
+		tracer = NewMemoryTracer()
+		testClient = NewClient(testServer.URL, "secret", nil, WithTracer(tracer))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	It("Annotates a span per call", func() {
+		failures = 1
+
+		var w Widget
+		err := testClient.Child("dinosaurs").OrderByKey().LimitToFirst(2).Value(&w)
+		Expect(err).To(BeNil())
+
+		spans := tracer.Spans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("firebase GET"))
+		Expect(spans[0].Parent).To(BeNil())
+		Expect(spans[0].Errors).To(BeEmpty())
+		Expect(spans[0].Attributes).To(Equal(map[string]interface{}{
+			AttributeMethod:       "GET",
+			AttributeURL:          testServer.URL + "/dinosaurs",
+			AttributeParams:       "limitToFirst=2&orderBy=%22%24key%22",
+			AttributeStatusCode:   int64(200),
+			AttributeRetries:      int64(1),
+			AttributeResponseSize: int64(7),
+		}))
+	})
+
+	It("Records errors, and leaves credentials out of params", func() {
+		failures = 100
+
+		_, err := testClient.Set("a", 1, map[string]string{"auth": "override", "x": "y"})
+		Expect(err).To(HaveOccurred())
+
+		spans := tracer.Spans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("firebase PUT"))
+		Expect(spans[0].Errors).To(Equal([]error{err}))
+		Expect(spans[0].Attributes[AttributeParams]).To(Equal("x=y"))
+		Expect(spans[0].Attributes[AttributeStatusCode]).To(Equal(int64(500)))
+		Expect(spans[0].Attributes[AttributeRetries]).To(Equal(int64(10)))
+	})
+
+	It("Starts spans as children of the span in the client's context", func() {
+		ctx, parent := tracer.Start(context.Background(), "handler")
+
+		var w Widget
+		ref := testClient.WithContext(ctx).Child("a")
+		Expect(ref.Value(&w)).To(BeNil())
+		Expect(ref.LimitToFirst(1).Value(&w)).To(BeNil())
+
+		stop := make(chan bool)
+		defer close(stop)
+		_, err := ref.Watch(nil, stop)
+		Expect(err).To(BeNil())
+
+		parent.End()
+
+		spans := tracer.Spans()
+		Expect(spans).To(HaveLen(4))
+		Expect(spans[2].Name).To(Equal("firebase stream"))
+		Expect(spans[3].Name).To(Equal("handler"))
+		for _, span := range spans[:3] {
+			Expect(span.Parent).To(Equal(spans[3]))
+		}
+	})
+
+	It("Cancels calls along with the client's context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var w Widget
+		err := testClient.WithContext(ctx).Value(&w)
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	})
+
+	It("Makes calls without context through other Api implementations", func() {
+		api := &recordingAPI{}
+		c := NewClient(testServer.URL, "", api).WithContext(context.Background())
+
+		var w Widget
+		Expect(c.Value(&w)).To(BeNil())
+		Expect(api.calls).To(Equal(1))
+	})
+})
+
+// recordingAPI is an Api implementation that only counts calls.
+type recordingAPI struct {
+	calls int
+}
+
+func (a *recordingAPI) Call(method, path, auth string, body interface{}, params map[string]string, dest interface{}) error {
+	a.calls++
+	return nil
+}
+
+func (a *recordingAPI) Stream(path, auth string, body interface{}, params map[string]string, stop <-chan bool) (<-chan RawEvent, error) {
+	return nil, nil
+}
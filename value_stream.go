@@ -0,0 +1,106 @@
+package firebase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrStopStream can be returned by a ValueStream callback to stop reading the
+// response early. ValueStream then returns nil.
+var ErrStopStream = errors.New("firebase: stop value stream")
+
+// ChildCallback is called by ValueStream with the key and raw JSON value of
+// each child of the location, in the order they arrive. Returning a non-nil
+// error stops the stream.
+type ChildCallback func(key string, raw json.RawMessage) error
+
+// streamingDestination is a Call destination that decodes the response body
+// itself, straight from the decoder. The default Api implementation checks
+// for it, so that large responses never have to be held in memory.
+type streamingDestination interface {
+	decodeStream(decoder *json.Decoder) error
+}
+
+// childStream is the destination used by ValueStream. It hands each child of
+// the response's top-level object to its callback.
+type childStream struct {
+	callback ChildCallback
+}
+
+func (s *childStream) decodeStream(decoder *json.Decoder) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case nil:
+		return nil
+	case json.Delim('{'):
+	default:
+		return fmt.Errorf("firebase: cannot stream the children of %v, it is not an object", token)
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		key, _ := token.(string)
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+
+		if err := s.callback(key, raw); err != nil {
+			return err
+		}
+	}
+
+	_, err = decoder.Token()
+	return err
+}
+
+// UnmarshalJSON lets Api implementations that don't know about streaming
+// destinations decode into a childStream. The whole response is in memory by
+// then, but the callback still sees every child.
+func (s *childStream) UnmarshalJSON(b []byte) error {
+	var children map[string]json.RawMessage
+	if err := json.Unmarshal(b, &children); err != nil {
+		return err
+	}
+
+	for key, raw := range children {
+		if err := s.callback(key, raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// valueStream GETs the value at the client's location, filtered by params,
+// and calls callback for each of its children as they are decoded.
+func (c *client) valueStream(params map[string]string, callback ChildCallback) error {
+	err := c.value(params, &childStream{callback: callback})
+	if err == ErrStopStream {
+		return nil
+	}
+
+	return err
+}
+
+func (c *client) ValueStream(callback ChildCallback) error {
+	return c.valueStream(nil, callback)
+}
+
+func (q *query) ValueStream(callback ChildCallback) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	return q.ref.valueStream(q.params, callback)
+}
@@ -0,0 +1,107 @@
+package firebase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streaming the children of a large value", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		body       string
+	)
+
+	JustBeforeEach(func() {
+		testServer, testClient = fakeServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal("GET"))
+				fmt.Fprint(w, body)
+			}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	Context("When the value is an object", func() {
+		BeforeEach(func() {
+			body = `{"a": {"x": [1, 2]}, "b": "two", "c": 3}`
+		})
+
+		It("Calls back with each child in the order it arrives", func() {
+			keys := []string{}
+			values := []string{}
+
+			err := testClient.ValueStream(func(key string, raw json.RawMessage) error {
+				keys = append(keys, key)
+				values = append(values, string(raw))
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(keys).To(Equal([]string{"a", "b", "c"}))
+			Expect(values).To(Equal([]string{`{"x": [1, 2]}`, `"two"`, "3"}))
+		})
+
+		It("Stops early without an error", func() {
+			calls := 0
+			err := testClient.ValueStream(func(key string, raw json.RawMessage) error {
+				calls++
+				return ErrStopStream
+			})
+			Expect(err).To(BeNil())
+			Expect(calls).To(Equal(1))
+		})
+
+		It("Returns the callback's error", func() {
+			crash := errors.New("crash")
+			err := testClient.ValueStream(func(key string, raw json.RawMessage) error {
+				return crash
+			})
+			Expect(err).To(Equal(crash))
+		})
+	})
+
+	Context("When the value is null", func() {
+		BeforeEach(func() {
+			body = "null"
+		})
+
+		It("Does not call back", func() {
+			err := testClient.ValueStream(func(key string, raw json.RawMessage) error {
+				Fail("unexpected child " + key)
+				return nil
+			})
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("When the value is not an object", func() {
+		BeforeEach(func() {
+			body = "42"
+		})
+
+		It("Returns an error", func() {
+			err := testClient.ValueStream(func(key string, raw json.RawMessage) error {
+				return nil
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("Falls back to decoding the whole value with other Api implementations", func() {
+		stream := &childStream{callback: func(key string, raw json.RawMessage) error {
+			Expect(key).To(Equal("a"))
+			Expect(string(raw)).To(Equal("1"))
+			return nil
+		}}
+
+		Expect(json.Unmarshal([]byte(`{"a": 1}`), stream)).To(Succeed())
+	})
+})
@@ -0,0 +1,230 @@
+package firebase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxWriteTimeout is the longest timeout Firebase accepts for a write.
+const maxWriteTimeout = 15 * time.Minute
+
+// errSilentPush is returned when Push is asked to be silent, since the key of
+// the new child is only known from Firebase's response.
+var errSilentPush = errors.New("firebase: Push cannot be silent, its response carries the new key")
+
+// errConditionalPush is returned when Push is made conditional, since the new
+// child has no ETag to match yet.
+var errConditionalPush = errors.New("firebase: Push cannot be conditional, the new child has no ETag yet")
+
+// errSilentEcho is returned when a write is asked to be both silent and
+// echoed.
+var errSilentEcho = errors.New("firebase: a silent write has no echo to decode")
+
+// SizeLimit bounds the size of a write, by how long Firebase estimates the
+// write will take. Writes over the limit are rejected by Firebase.
+// https://firebase.google.com/docs/database/rest/save-data#section-param-write-size-limit
+type SizeLimit string
+
+const (
+	SizeLimitTiny      SizeLimit = "tiny"      // a target of 1 second
+	SizeLimitSmall     SizeLimit = "small"     // a target of 10 seconds
+	SizeLimitMedium    SizeLimit = "medium"    // a target of 30 seconds
+	SizeLimitLarge     SizeLimit = "large"     // a target of 60 seconds
+	SizeLimitUnlimited SizeLimit = "unlimited" // no limit
+)
+
+// WriteOption configures a single write: Push, Set, SetWithPriority, Update or
+// Remove.
+type WriteOption func(*writeOptions)
+
+// writeOptions holds the configuration of a write.
+type writeOptions struct {
+	// params are the query string params sent with the write. They start as
+	// a copy of the params passed to the write method.
+	params map[string]string
+
+	// silent is set when Firebase is asked not to echo the written value.
+	silent bool
+
+	// echo is where the value echoed by Firebase is decoded, if anywhere.
+	echo interface{}
+
+	// etag is where the ETag of the written location is stored, if anywhere.
+	etag *string
+
+	// ifMatch is the ETag the location must have for the write to be made,
+	// if any.
+	ifMatch string
+
+	// err is the first invalid option found.
+	err error
+}
+
+// newWriteOptions applies options on top of the params given to a write.
+func newWriteOptions(params map[string]string, options []WriteOption) (*writeOptions, error) {
+	if len(options) == 0 {
+		return &writeOptions{params: params}, nil
+	}
+
+	opts := &writeOptions{params: make(map[string]string, len(params))}
+	for key, value := range params {
+		opts.params[key] = value
+	}
+
+	for _, option := range options {
+		option(opts)
+	}
+
+	if opts.silent && opts.echo != nil {
+		opts.fail(errSilentEcho)
+	}
+
+	return opts, opts.err
+}
+
+// destination returns the Call destination of the write: the echo
+// destination, wrapped to also capture the ETag, or to make the write
+// conditional, if asked for. codec is the client's, which decodes the echo.
+func (opts *writeOptions) destination(codec Codec) interface{} {
+	if opts.etag == nil && opts.ifMatch == "" {
+		return opts.echo
+	}
+
+	return &etagDestination{destination: opts.echo, etag: opts.etag,
+		ifMatch: opts.ifMatch, codec: codec}
+}
+
+// Silent asks Firebase not to echo the written value back (print=silent). It
+// saves bandwidth on large writes; Firebase answers with 204 No Content.
+func Silent() WriteOption {
+	return func(opts *writeOptions) {
+		opts.silent = true
+		opts.params["print"] = "silent"
+	}
+}
+
+// Echo decodes the value Firebase echoes back after the write into
+// destination. Server values, such as ServerTime and Increment, are resolved
+// in the echo. Since Firebase only answers a Push with the new key, a Push
+// that is echoed reads the new child back with a second request.
+func Echo(destination interface{}) WriteOption {
+	return func(opts *writeOptions) {
+		opts.echo = destination
+	}
+}
+
+// ETag stores the ETag of the written location in etag, once the write is
+// done. It is left empty if Firebase doesn't send one.
+// https://firebase.google.com/docs/database/rest/save-data#section-conditional-requests
+func ETag(etag *string) WriteOption {
+	return func(opts *writeOptions) {
+		opts.etag = etag
+	}
+}
+
+// IfMatch makes the write conditional: it is only made if the location's ETag
+// is still etag (if-match). Otherwise Firebase rejects it, and the write
+// returns an *ETagMismatchError holding the location's current ETag. An empty
+// etag makes the write unconditional.
+// https://firebase.google.com/docs/database/rest/save-data#section-conditional-requests
+func IfMatch(etag string) WriteOption {
+	return func(opts *writeOptions) {
+		opts.ifMatch = etag
+	}
+}
+
+// ETagMismatchError is returned by a write made with IfMatch when the location
+// changed: Firebase answered it with 412 Precondition Failed.
+type ETagMismatchError struct {
+	// ETag is the location's current ETag.
+	ETag string
+}
+
+func (e *ETagMismatchError) Error() string {
+	return fmt.Sprintf("firebase: the location changed, its ETag is now %q", e.ETag)
+}
+
+// WriteSizeLimit makes Firebase reject the write if it is larger than limit
+// (writeSizeLimit).
+func WriteSizeLimit(limit SizeLimit) WriteOption {
+	return func(opts *writeOptions) {
+		switch limit {
+		case SizeLimitTiny, SizeLimitSmall, SizeLimitMedium, SizeLimitLarge,
+			SizeLimitUnlimited:
+			opts.params["writeSizeLimit"] = string(limit)
+		default:
+			opts.fail(fmt.Errorf("firebase: unknown write size limit %q", limit))
+		}
+	}
+}
+
+// Timeout makes Firebase abort the write if it takes longer than timeout on
+// the server (timeout). It must be positive and at most 15 minutes, and is
+// rounded up to a whole millisecond.
+func Timeout(timeout time.Duration) WriteOption {
+	return func(opts *writeOptions) {
+		if timeout <= 0 || timeout > maxWriteTimeout {
+			opts.fail(fmt.Errorf("firebase: write timeout %v is not between 0 and %v",
+				timeout, maxWriteTimeout))
+			return
+		}
+
+		opts.params["timeout"] = formatTimeout(timeout)
+	}
+}
+
+// fail records err, unless an earlier option already failed.
+func (opts *writeOptions) fail(err error) {
+	if opts.err == nil {
+		opts.err = err
+	}
+}
+
+// formatTimeout formats a duration the way Firebase's timeout param expects
+// it: a whole number of minutes, seconds or milliseconds.
+func formatTimeout(timeout time.Duration) string {
+	switch {
+	case timeout%time.Minute == 0:
+		return fmt.Sprintf("%dmin", timeout/time.Minute)
+	case timeout%time.Second == 0:
+		return fmt.Sprintf("%ds", timeout/time.Second)
+	}
+
+	ms := (timeout + time.Millisecond - 1) / time.Millisecond
+	return fmt.Sprintf("%dms", ms)
+}
+
+// etagDestination is a Call destination that asks Firebase for the ETag of
+// the location, and stores it once the response's body is decoded into the
+// destination it wraps. It also carries the if-match ETag of conditional
+// writes. The default Api implementation checks for it.
+type etagDestination struct {
+	destination interface{}
+
+	// etag is where the ETag is stored, if anywhere.
+	etag *string
+
+	// ifMatch is sent as the if-match header, unless empty.
+	ifMatch string
+
+	// codec decodes into the destination when an Api implementation
+	// unmarshals the etagDestination itself. If nil, the default codec is
+	// used.
+	codec Codec
+}
+
+// UnmarshalJSON lets Api implementations that don't know about ETags decode
+// into the wrapped destination. The ETag is left empty.
+func (e *etagDestination) UnmarshalJSON(b []byte) error {
+	if e.destination == nil {
+		return nil
+	}
+
+	codec := e.codec
+	if codec == nil {
+		codec = defaultCodec
+	}
+
+	return codec.Unmarshal(b, e.destination)
+}
@@ -0,0 +1,230 @@
+package firebase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Write options", func() {
+	var (
+		testServer *httptest.Server
+		testClient *client
+		handler    func(w http.ResponseWriter, r *http.Request)
+		requests   int
+	)
+
+	JustBeforeEach(func() {
+		requests = 0
+		testServer, testClient = fakeServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			handler(w, r)
+		}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	Context("Silent", func() {
+		BeforeEach(func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Query().Get("print")).To(Equal("silent"))
+				_, err := io.ReadAll(r.Body)
+				Expect(err).To(BeNil())
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+
+		It("Handles the 204 answer to a silent Set", func() {
+			ref, err := testClient.Set("a", Widget{A: 1}, nil, Silent())
+			Expect(err).To(BeNil())
+			Expect(ref.Path()).To(Equal(Path{"a"}))
+			Expect(requests).To(Equal(1))
+		})
+
+		It("Handles the 204 answer to silent Updates and Removes", func() {
+			Expect(testClient.Update("a", Widget{A: 1}, nil, Silent())).To(BeNil())
+			Expect(testClient.Remove("a", nil, Silent())).To(BeNil())
+			Expect(requests).To(Equal(2))
+		})
+
+		It("Refuses a silent Push", func() {
+			_, err := testClient.Push(Widget{A: 1}, nil, Silent())
+			Expect(err).To(Equal(errSilentPush))
+			Expect(requests).To(Equal(0))
+		})
+	})
+
+	Context("Params", func() {
+		var query map[string]string
+
+		BeforeEach(func() {
+			query = nil
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				query = map[string]string{}
+				for key := range r.URL.Query() {
+					query[key] = r.URL.Query().Get(key)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+
+		It("Sends the size limit and timeout along with the write's params", func() {
+			params := map[string]string{"custom": "1"}
+
+			err := testClient.Update("a", Widget{A: 1}, params,
+				WriteSizeLimit(SizeLimitSmall), Timeout(3*time.Second))
+			Expect(err).To(BeNil())
+			Expect(query).To(Equal(map[string]string{
+				"custom":         "1",
+				"writeSizeLimit": "small",
+				"timeout":        "3s",
+			}))
+			Expect(params).To(Equal(map[string]string{"custom": "1"}))
+		})
+
+		It("Sends the options with SetWithPriority", func() {
+			_, err := testClient.SetWithPriority("a", 1, 2, Silent())
+			Expect(err).To(BeNil())
+			Expect(query).To(HaveKeyWithValue("print", "silent"))
+		})
+
+		It("Rejects invalid options before making a request", func() {
+			err := testClient.Update("a", 1, nil, WriteSizeLimit("huge"))
+			Expect(err).To(HaveOccurred())
+
+			_, err = testClient.Set("a", 1, nil, Timeout(time.Hour))
+			Expect(err).To(HaveOccurred())
+
+			err = testClient.Remove("a", nil, Timeout(0))
+			Expect(err).To(HaveOccurred())
+
+			Expect(requests).To(Equal(0))
+		})
+	})
+
+	Context("Echo", func() {
+		var etagRequested []string
+
+		BeforeEach(func() {
+			etagRequested = nil
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				etagRequested = append(etagRequested, r.Header.Get("X-Firebase-ETag"))
+				w.Header().Set("ETag", "etag-"+r.Method)
+
+				switch r.Method {
+				case "POST":
+					fmt.Fprint(w, `{"name":"-new"}`)
+				case "GET":
+					Expect(r.URL.Path).To(Equal("/a/-new.json"))
+					fmt.Fprint(w, `{"A":7,"B":1600000000000}`)
+				default:
+					fmt.Fprint(w, `{"A":7,"B":1500000000000}`)
+				}
+			}
+		})
+
+		It("Decodes the stored value, and returns its ETag, from SetAndGet", func() {
+			var stored struct {
+				A int
+				B MillisTime
+			}
+
+			ref, etag, err := testClient.SetAndGet("a", map[string]interface{}{
+				"A": 7, "B": ServerTime,
+			}, &stored)
+			Expect(err).To(BeNil())
+			Expect(ref.Path()).To(Equal(Path{"a"}))
+			Expect(etag).To(Equal("etag-PUT"))
+			Expect(stored.A).To(Equal(7))
+			Expect(time.Time(stored.B)).To(Equal(time.UnixMilli(1500000000000)))
+			Expect(etagRequested).To(Equal([]string{"true"}))
+		})
+
+		It("Decodes the echo of an Update", func() {
+			var stored Widget
+			var etag string
+
+			err := testClient.Update("a", map[string]interface{}{"A": Increment(1)}, nil,
+				Echo(&stored), ETag(&etag))
+			Expect(err).To(BeNil())
+			Expect(stored.A).To(Equal(7))
+			Expect(etag).To(Equal("etag-PATCH"))
+		})
+
+		It("Reads back the new child of a Push", func() {
+			var stored Widget
+			var etag string
+
+			ref, err := testClient.Child("a").Push(Widget{A: 7}, nil, Echo(&stored), ETag(&etag))
+			Expect(err).To(BeNil())
+			Expect(ref.Key()).To(Equal("-new"))
+			Expect(stored.A).To(Equal(7))
+			Expect(etag).To(Equal("etag-GET"))
+			Expect(etagRequested).To(Equal([]string{"", "true"}))
+		})
+
+		It("Doesn't ask for an ETag unless needed", func() {
+			var stored Widget
+
+			Expect(testClient.Update("a", Widget{A: 7}, nil, Echo(&stored))).To(BeNil())
+			Expect(stored.A).To(Equal(7))
+			Expect(etagRequested).To(Equal([]string{""}))
+		})
+
+		It("Refuses to echo a silent write", func() {
+			var stored Widget
+
+			err := testClient.Update("a", Widget{A: 7}, nil, Silent(), Echo(&stored))
+			Expect(err).To(Equal(errSilentEcho))
+			Expect(requests).To(Equal(0))
+		})
+	})
+
+	Context("IfMatch", func() {
+		BeforeEach(func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", "current")
+				if match := r.Header.Get("if-match"); match != "" && match != "current" {
+					w.WriteHeader(http.StatusPreconditionFailed)
+					fmt.Fprint(w, `{"A":1}`)
+					return
+				}
+				fmt.Fprint(w, `{"A":7}`)
+			}
+		})
+
+		It("Writes if the location didn't change", func() {
+			var etag string
+
+			_, err := testClient.Set("a", Widget{A: 7}, nil, IfMatch("current"), ETag(&etag))
+			Expect(err).To(BeNil())
+			Expect(etag).To(Equal("current"))
+		})
+
+		It("Fails without retrying if the location changed", func() {
+			err := testClient.Remove("a", nil, IfMatch("stale"))
+			Expect(err).To(Equal(&ETagMismatchError{ETag: "current"}))
+			Expect(requests).To(Equal(1))
+		})
+
+		It("Refuses a conditional Push", func() {
+			_, err := testClient.Push(Widget{A: 7}, nil, IfMatch("current"))
+			Expect(err).To(Equal(errConditionalPush))
+			Expect(requests).To(Equal(0))
+		})
+	})
+
+	It("Formats timeouts in the largest whole unit", func() {
+		Expect(formatTimeout(2 * time.Minute)).To(Equal("2min"))
+		Expect(formatTimeout(90 * time.Second)).To(Equal("90s"))
+		Expect(formatTimeout(1500 * time.Millisecond)).To(Equal("1500ms"))
+		Expect(formatTimeout(1500 * time.Microsecond)).To(Equal("2ms"))
+	})
+})